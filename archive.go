@@ -0,0 +1,289 @@
+package csv2table
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dateTpl is replaced by the current date (YYYY-MM-DD) in archive directories
+const dateTpl = "{date}"
+
+// ArchiveFile moves an imported file to Config.ArchiveDir, or to Config.ErrorDir if its import failed.
+// Cancelled files are left in place, to be imported again by the next run.
+// It returns the new location of the file, or an empty string if the file was not moved.
+func ArchiveFile(config Config, status ImportFileStatus, now time.Time) (string, error) {
+	var dir string
+	switch status.Status {
+	case StatusImported, StatusSkipped:
+		dir = config.ArchiveDir
+	case StatusFailed:
+		dir = config.ErrorDir
+	}
+
+	if dir == "" {
+		return "", nil
+	}
+
+	dir = strings.Replace(dir, dateTpl, now.Format("2006-01-02"), -1)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	src := status.Path
+	if src == "" {
+		src = status.FileName
+	}
+
+	dest := filepath.Join(dir, filepath.Base(src))
+	compress := config.ArchiveCompress && !isCompressed(src)
+	if compress {
+		dest += extGzip
+	}
+
+	// a file of the same name may already be archived, e.g. imported again the same day
+	dest, err = reserveFile(dest)
+	if err != nil {
+		return "", err
+	}
+
+	if compress {
+		err = gzipFile(src, dest)
+	} else {
+		err = moveFile(src, dest)
+	}
+	if err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+
+	// retention is calculated from the archive time
+	err = os.Chtimes(dest, now, now)
+	if err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
+
+// CleanArchive deletes the files archived more than Config.ArchiveRetention days ago, from both archive
+// directories. Only the archived files and the dated directories created by ArchiveFile are deleted.
+// The archive directories must not hold the working directory or any of importDirs, the directories
+// the files are imported from.
+func CleanArchive(config Config, now time.Time, importDirs ...string) error {
+	if config.ArchiveRetention <= 0 {
+		return nil
+	}
+
+	cutoff := now.AddDate(0, 0, -config.ArchiveRetention)
+	for _, dir := range []string{config.ArchiveDir, config.ErrorDir} {
+		if dir == "" {
+			continue
+		}
+
+		root, err := archiveRoot(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, importDir := range append([]string{"."}, importDirs...) {
+			if containsDir(root, importDir) {
+				return fmt.Errorf("archive retention requires %s to be outside of the import directory %s", dir, importDir)
+			}
+		}
+
+		err = cleanArchiveDir(dir, root, cutoff)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveRoot returns the directory holding all archives created from a templated directory,
+// e.g. "archive" for "archive/{date}"
+func archiveRoot(dir string) (string, error) {
+	root := filepath.Clean(dir)
+	if i := strings.Index(dir, dateTpl); i >= 0 {
+		// the parent of the templated path element
+		root = filepath.Dir(dir[:i] + "x")
+	}
+
+	// never clean the working directory, it holds the files to be imported
+	if root == "." || root == string(filepath.Separator) {
+		return "", fmt.Errorf("archive retention requires %s to be inside its own directory", dir)
+	}
+
+	return root, nil
+}
+
+// containsDir checks whether dir is sub, or one of its parents
+func containsDir(dir string, sub string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	absSub, err := filepath.Abs(sub)
+	if err != nil {
+		return true
+	}
+
+	rel, err := filepath.Rel(absDir, absSub)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// cleanArchiveDir deletes the files archived into dir before cutoff. When dir is templated, the dated directories
+// of root matching the template are cleaned, and deleted once empty.
+func cleanArchiveDir(dir string, root string, cutoff time.Time) error {
+	if !strings.Contains(dir, dateTpl) {
+		return cleanFiles(root, cutoff)
+	}
+
+	// the templated path element, e.g. "{date}" for "archive/{date}/in", and the path below it
+	rel, err := filepath.Rel(root, filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+	elem, below := rel, ""
+	if i := strings.Index(rel, string(filepath.Separator)); i >= 0 {
+		elem, below = rel[:i], rel[i+1:]
+	}
+	pattern := regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(elem), regexp.QuoteMeta(dateTpl),
+		`(\d{4}-\d{2}-\d{2})`, -1) + "$")
+
+	entries, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		match := pattern.FindStringSubmatch(entry.Name())
+		if !entry.IsDir() || match == nil {
+			continue
+		}
+
+		dated := filepath.Join(root, entry.Name())
+		archived := filepath.Join(dated, strings.Replace(below, dateTpl, match[1], -1))
+		err = cleanFiles(archived, cutoff)
+		if err != nil {
+			return err
+		}
+
+		// the directories left empty, a failure means the directory is not empty
+		for path := archived; path != root; path = filepath.Dir(path) {
+			if os.Remove(path) != nil {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// cleanFiles deletes the archived files of dir modified before cutoff, the other files and the subdirectories
+// are not archives and are left in place
+func cleanFiles(dir string, cutoff time.Time) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !IsInputFile(entry.Name()) || !entry.ModTime().Before(cutoff) {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserveFile creates an empty file named dest, or named like dest with a counter after its base name if dest
+// exists, e.g. sales_2.csv.gz for sales.csv.gz. It returns the name of the created file.
+func reserveFile(dest string) (string, error) {
+	dir, name := filepath.Split(dest)
+	base := BaseName(name)
+
+	for i := 2; ; i++ {
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return dest, f.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+
+		dest = filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i, name[len(base):]))
+	}
+}
+
+// moveFile moves a file, copying it when renaming is not possible (e.g. across devices)
+func moveFile(src string, dest string) error {
+	if os.Rename(src, dest) == nil {
+		return nil
+	}
+
+	return copyAndRemove(src, dest, func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} })
+}
+
+// gzipFile compresses src into dest, then deletes src
+func gzipFile(src string, dest string) error {
+	return copyAndRemove(src, dest, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+}
+
+// copyAndRemove copies src into dest through the writer created by wrap, then deletes src
+func copyAndRemove(src string, dest string, wrap func(w io.Writer) io.WriteCloser) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	w := wrap(out)
+	_, err = io.Copy(w, in)
+	if err == nil {
+		err = w.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
+
+// nopWriteCloser adds a no-op Close method to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package csv2table
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csv2table")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	config := NewConfig()
+	config.ArchiveDir = filepath.Join(dir, "archive", "{date}")
+	config.ErrorDir = filepath.Join(dir, "failed")
+	now := time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+
+	imported := filepath.Join(dir, "sales.csv")
+	failed := filepath.Join(dir, "orders.csv")
+	ioutil.WriteFile(imported, []byte("a;b\n1;2\n"), 0644)
+	ioutil.WriteFile(failed, []byte("a;b\n1;2\n"), 0644)
+
+	dest, err := ArchiveFile(config, ImportFileStatus{FileName: imported, Status: StatusImported}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, dest, filepath.Join(dir, "archive", "2026-10-15", "sales.csv"))
+	assert.FileExists(t, dest)
+	assert.NoFileExists(t, imported)
+
+	ioutil.WriteFile(imported, []byte("a;b\n3;4\n"), 0644)
+	dest, err = ArchiveFile(config, ImportFileStatus{FileName: imported, Status: StatusImported}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, dest, filepath.Join(dir, "archive", "2026-10-15", "sales_2.csv"))
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "archive", "2026-10-15", "sales.csv"))
+	assert.Equal(t, string(data), "a;b\n1;2\n")
+
+	config.ArchiveCompress = true
+	dest, err = ArchiveFile(config, ImportFileStatus{FileName: failed, Status: StatusFailed}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, dest, filepath.Join(dir, "failed", "orders.csv.gz"))
+	assert.FileExists(t, dest)
+
+	// the files archived earlier with the same name are kept
+	for _, expected := range []string{"orders_2.csv.gz", "orders_3.csv.gz"} {
+		ioutil.WriteFile(failed, []byte("a;b\n3;4\n"), 0644)
+		dest, err = ArchiveFile(config, ImportFileStatus{FileName: failed, Status: StatusFailed}, now)
+		assert.Nil(t, err)
+		assert.Equal(t, dest, filepath.Join(dir, "failed", expected))
+	}
+	assert.FileExists(t, filepath.Join(dir, "failed", "orders.csv.gz"))
+
+	// retention
+	config.ArchiveRetention = 10
+	assert.Nil(t, CleanArchive(config, now.AddDate(0, 0, 5)))
+	assert.FileExists(t, filepath.Join(dir, "archive", "2026-10-15", "sales.csv"))
+	assert.Nil(t, CleanArchive(config, now.AddDate(0, 0, 11)))
+	assert.NoDirExists(t, filepath.Join(dir, "archive", "2026-10-15"))
+	assert.NoFileExists(t, filepath.Join(dir, "failed", "orders.csv.gz"))
+}
+
+func TestCleanArchive(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -30)
+
+	config := NewConfig()
+	config.ArchiveDir = filepath.Join(dir, "archive", "day-{date}", "in")
+	config.ErrorDir = filepath.Join(dir, "failed")
+	config.ArchiveRetention = 10
+
+	files := []string{
+		"archive/day-2026-09-15/in/sales.csv",
+		"archive/day-2026-10-14/in/sales.csv",
+		"archive/notes.csv",
+		"archive/2026-09-15/sales.csv",
+		"failed/orders.csv.gz",
+		"failed/readme.txt",
+		"failed/keep/orders.csv",
+		"incoming/waiting.csv",
+	}
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte("a;b\n1;2\n"), 0644))
+		assert.Nil(t, os.Chtimes(path, old, old))
+	}
+	recent := filepath.Join(dir, "archive/day-2026-10-14/in/sales.csv")
+	assert.Nil(t, os.Chtimes(recent, now, now))
+
+	assert.Nil(t, CleanArchive(config, now, filepath.Join(dir, "incoming")))
+
+	// only the archived files of the dated directories and of the error directory are deleted
+	assert.NoDirExists(t, filepath.Join(dir, "archive/day-2026-09-15"))
+	assert.FileExists(t, recent)
+	assert.FileExists(t, filepath.Join(dir, "archive/notes.csv"))
+	assert.FileExists(t, filepath.Join(dir, "archive/2026-09-15/sales.csv"))
+	assert.NoFileExists(t, filepath.Join(dir, "failed/orders.csv.gz"))
+	assert.FileExists(t, filepath.Join(dir, "failed/readme.txt"))
+	assert.FileExists(t, filepath.Join(dir, "failed/keep/orders.csv"))
+	assert.FileExists(t, filepath.Join(dir, "incoming/waiting.csv"))
+
+	// an archive holding the import directory is never cleaned
+	config.ArchiveDir = filepath.Join(dir, "{date}")
+	assert.NotNil(t, CleanArchive(config, now, filepath.Join(dir, "incoming")))
+	config.ArchiveDir = filepath.Join(dir, "incoming")
+	assert.NotNil(t, CleanArchive(config, now, filepath.Join(dir, "incoming")))
+	assert.FileExists(t, filepath.Join(dir, "incoming/waiting.csv"))
+}
+
+func TestArchiveRoot(t *testing.T) {
+	root, err := archiveRoot("archive/{date}")
+	assert.Nil(t, err)
+	assert.Equal(t, root, "archive")
+
+	_, err = archiveRoot("archive-{date}")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/schiorean/csv2table"
+)
+
+// stdoutName is the file name writing the csv data to stdout
+const stdoutName = "-"
+
+// Export exports a table as csv to a file, or to stdout if the file is "-". Like for its import, the global
+// configuration file is merged with the configuration file of the csv file, or with the one set by --config,
+// and the table defaults to the one the file is imported into. --query exports the rows of a query instead.
+// It returns the exit status of the process.
+func Export(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	table := flags.String("table", "", "exported table, required when writing to stdout without a query or configured table")
+	configFile := flags.String("config", "", "configuration file, merged with the global configuration file")
+	query := flags.String("query", "", "query whose rows are exported instead of a table")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: csv2table export [--table name] [--config file.toml] [--query sql] file|-")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	name := flags.Arg(0)
+	var options []csv2table.Option
+	if name == stdoutName && *configFile != "" {
+		options = append(options, csv2table.WithConfigFile(*configFile))
+	}
+
+	im, err := newImporter(options...)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	in := csv2table.Input{Table: *table}
+	if name == stdoutName {
+		_, err = im.Export(ctx, in, os.Stdout, *query)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		return 0
+	}
+
+	in.Name = filepath.Base(name)
+	in.Path = name
+	in.Config = *configFile
+	rows, err := exportFile(ctx, im, in, *query)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	log.Printf("%d rows exported to %s", rows, name)
+	return 0
+}
+
+// exportFile exports to a temporary file first, the existing file being replaced only by a complete export
+func exportFile(ctx context.Context, im *csv2table.Importer, in csv2table.Input, query string) (int, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(in.Path), "."+in.Name+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	rows, err := im.Export(ctx, in, tmp, query)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return rows, err
+	}
+
+	return rows, replaceFile(tmp.Name(), in.Path)
+}
+
+// replaceFile renames the temporary file tmp to path, keeping the permissions of the replaced file.
+// A new file is readable by everyone, unlike the temporary files.
+func replaceFile(tmp string, path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	err := os.Chmod(tmp, mode)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orders.csv")
+
+	// a new file
+	tmp, err := ioutil.TempFile(dir, ".orders.csv.*")
+	assert.Nil(t, err)
+	tmp.Close()
+	assert.Nil(t, replaceFile(tmp.Name(), path))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0644))
+
+	// the permissions of the replaced file are kept
+	assert.Nil(t, os.Chmod(path, 0640))
+	tmp, err = ioutil.TempFile(dir, ".orders.csv.*")
+	assert.Nil(t, err)
+	tmp.WriteString("id\n1\n")
+	tmp.Close()
+	assert.Nil(t, replaceFile(tmp.Name(), path))
+
+	info, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0640))
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), "id\n1\n")
+}
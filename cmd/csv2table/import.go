@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/schiorean/csv2table"
+)
+
+// stdinName is the file name reading the csv data from stdin
+const stdinName = "-"
+
+// Import imports a single csv file, or the csv data read from stdin if the file is "-".
+// The global configuration file is read from the working directory, merged with the configuration file
+// of the csv file, or with the one set by --config. It returns the exit status of the process, 1 if the import failed.
+func Import(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	table := flags.String("table", "", "target table, required when reading stdin")
+	configFile := flags.String("config", "", "configuration file, merged with the global configuration file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: csv2table import [--table name] [--config file.toml] file|-")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var statuses []csv2table.ImportFileStatus
+	var err error
+	if name := flags.Arg(0); name == stdinName {
+		statuses, err = importStdin(ctx, *table, *configFile)
+	} else {
+		statuses, err = importPath(ctx, name, *table, *configFile)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	for _, status := range statuses {
+		if status.Error != nil {
+			log.Printf("%s not imported", status.FileName)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// importPath imports a file, like Run does, without requiring a configuration file
+func importPath(ctx context.Context, name string, table string, configFile string) ([]csv2table.ImportFileStatus, error) {
+	im, err := newImporter()
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, err := csv2table.FileInputs(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range inputs {
+		inputs[i].Table = table
+		inputs[i].Config = configFile
+	}
+
+	return im.ImportInputs(ctx, inputs)
+}
+
+// importStdin imports the csv data read from stdin
+func importStdin(ctx context.Context, table string, configFile string) ([]csv2table.ImportFileStatus, error) {
+	var options []csv2table.Option
+	if configFile != "" {
+		options = append(options, csv2table.WithConfigFile(configFile))
+	}
+
+	im, err := newImporter(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := im.ImportReader(ctx, os.Stdin, table)
+	if status.FileName == "" {
+		// no table, nothing was imported
+		return nil, err
+	}
+
+	return []csv2table.ImportFileStatus{status}, nil
+}
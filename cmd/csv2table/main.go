@@ -1,201 +1,210 @@
 package main
 
 import (
-	"encoding/csv"
-	"fmt"
-	"io"
-	"io/ioutil"
+	"context"
 	"log"
 	"os"
-	"strings"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/schiorean/csv2table"
 	"github.com/schiorean/csv2table/mysql"
-
-	"github.com/spf13/viper"
+	"github.com/schiorean/csv2table/source"
 )
 
+// globalConfigFile is the global configuration file, read from the working directory
+const globalConfigFile = csv2table.DirConfigFile
+
+// pool shares the database connections of the imported files
+var pool = mysql.NewPool()
+
 // main is the entry routine
+//
+// Usage:
+//
+//	csv2table              import the csv files of the working directory
+//	csv2table watch [dir]  import the csv files of dir as they arrive
+//	csv2table import [--table name] [--config file.toml] file|-
+//	                       import a single csv file, or the csv data read from stdin
+//	csv2table export [--table name] [--config file.toml] [--query sql] file|-
+//	                       export a table as csv, to a file or to stdout
+//	csv2table validate [--config file.toml] [file...]
+//	                       check the configuration without importing
 func main() {
-	Run(".")
+	os.Exit(run())
 }
 
-// Run function is the main routine that starts the csv import process
-func Run(directory string) {
-	var err error
-
-	// iterate through all files in the directory
-	// and find all csv files that have a matching configuration file (.toml)
-	files, err := ioutil.ReadDir(directory)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// import status list collected from each processed file
-	statuses := make([]csv2table.ImportFileStatus, 0, len(files))
-
-	found := false
-	for _, f := range files {
-		if strings.HasSuffix(strings.ToLower(f.Name()), ".csv") {
-			found = true
-
-			// in order for a file to be processed it must have a matching configuration file
-			if !hasConfigFile(f.Name()) {
-				continue
+// run runs the command of the arguments and returns the exit status. The process exits only once run returns,
+// so that the deferred cleanups, such as closing the pooled connections, are done on failures too.
+func run() int {
+	// Ctrl-C or a service stop cancels the import, a second one kills the process if the shutdown is stuck
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+	defer pool.Close()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "watch":
+			directory := "."
+			if len(os.Args) > 2 {
+				directory = os.Args[2]
 			}
 
-			// mysql service, for now
-			service := mysql.NewService()
-
-			rowCount, err := processCsv(service, f.Name())
-			if err != nil {
-				log.Fatalf("error while processing %s, %v", f.Name(), err)
-			}
-
-			// add import status
-			statuses = append(statuses, csv2table.ImportFileStatus{
-				FileName: f.Name(),
-				Error:    err,
-				RowCount: rowCount,
-			})
+			return Watch(ctx, directory)
+		case "import":
+			return Import(ctx, os.Args[2:])
+		case "export":
+			return Export(ctx, os.Args[2:])
+		case "validate":
+			return Validate(os.Args[2:])
 		}
 	}
 
-	if !found {
-		log.Println("no files found")
-	}
-
-	err = csv2table.AfterImport(statuses)
-	if err != nil {
-		log.Fatalf("error while running after import routine, %v", err)
-	}
+	return Run(ctx, ".")
 }
 
-// processCsv reads a a csv file and imports it into a database table with similar structure
-func processCsv(service csv2table.DbService, fileName string) (int, error) {
-	v, err := getFileViper(fileName)
+// Run function is the main routine that starts the csv import process.
+// When ctx is cancelled, the file being imported keeps only its committed batches
+// and the remaining files are not imported, all of them being reported as cancelled.
+// It returns the exit status of the process, 1 if any file failed, so that schedulers notice it.
+func Run(ctx context.Context, directory string) int {
+	im, err := newImporter()
 	if err != nil {
-		return 0, err
+		log.Print(err)
+		return 1
 	}
 
-	// unmarshall generic (non db provider)  configuration
-	if v != nil {
-		err := csv2table.UnmarshallConfig(v)
-		if err != nil {
-			return 0, err
-		}
-	}
+	// remote files are downloaded first, then imported like local files
+	fetched := fetchSources(ctx, im.Config())
 
-	// initialize service
-	err = service.Start(fileName, v)
+	statuses, err := im.ImportDir(ctx, directory)
+	finishSources(ctx, fetched, statuses)
 	if err != nil {
-		return 0, err
+		log.Print(err)
+		return 1
 	}
-	defer service.End()
 
-	// all good, now start csv file processing
-	f, err := os.Open(fileName)
-	if err != nil {
-		return 0, err
+	if failed := failedFiles(statuses); failed > 0 {
+		log.Printf("%d file(s) not imported", failed)
+		return 1
 	}
-	defer f.Close()
 
-	r := csv.NewReader(f)
-	r.Comma = ';'
-
-	// first line is always the header
-	header, err := r.Read()
-	if err != nil {
-		return 0, err
-	}
-	err = service.ProcessHeader(header)
-	if err != nil {
-		return 0, err
-	}
+	return 0
+}
 
-	rowCount := 0
-	for {
-		line, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return 0, err
+// failedFiles returns the number of files whose import failed or was cancelled
+func failedFiles(statuses []csv2table.ImportFileStatus) int {
+	failed := 0
+	for _, status := range statuses {
+		if status.Error != nil {
+			failed++
 		}
+	}
 
-		rowCount++
+	return failed
+}
 
-		// rest of the lines are content
-		err = service.ProcessLine(line)
-		if err != nil {
-			return rowCount, err
-		}
+// newImporter creates an importer with the mysql backend, reading the global configuration file
+// csv2table.toml from the working directory, if any
+func newImporter(options ...csv2table.Option) (*csv2table.Importer, error) {
+	defaults := []csv2table.Option{
+		// mysql service, for now, the files reusing the connections of the previous files
+		csv2table.WithBackend(func() csv2table.DbService { return pool.NewService() }),
 	}
 
-	// signal end of csv file
-	err = service.End()
-	if err != nil {
-		return rowCount, err
+	if _, err := os.Stat(globalConfigFile); err == nil {
+		defaults = append(defaults, csv2table.WithConfigFile(globalConfigFile))
 	}
 
-	return rowCount, nil
+	return csv2table.NewImporter(append(defaults, options...)...)
 }
 
-// getGlobalViper reads global viper configuration from csv2table.toml
-func getGlobalViper() (*viper.Viper, error) {
-	var v *viper.Viper
+// fetchedSource holds the files downloaded from a source
+type fetchedSource struct {
+	config csv2table.SourceConfig
+	files  []csv2table.FetchedFile
+}
 
-	// try load global config
-	if _, err := os.Stat("csv2table.toml"); err == nil {
-		v = viper.New()
-		v.SetConfigFile("csv2table.toml")
+// fetchSources downloads the files of the configured sources.
+// A failing source is logged, the files already downloaded are imported anyway.
+func fetchSources(ctx context.Context, config csv2table.Config) []fetchedSource {
+	var fetched []fetchedSource
+	for _, sourceConfig := range config.Sources {
+		src, err := source.New(sourceConfig)
+		if err != nil {
+			log.Printf("unable to connect to %s source, %v", sourceConfig.Type, err)
+			continue
+		}
 
-		err := v.ReadInConfig()
+		files, err := csv2table.FetchFiles(ctx, src, sourceConfig)
 		if err != nil {
-			return nil, fmt.Errorf("unable to read global config file, %v", err)
+			log.Printf("unable to fetch files from %s source, %v", sourceConfig.Type, err)
+		}
+		src.Close()
+
+		for _, file := range files {
+			log.Printf("fetched %s to %s", file.Name, file.Path)
 		}
+		fetched = append(fetched, fetchedSource{config: sourceConfig, files: files})
 	}
 
-	return v, nil
+	return fetched
 }
 
-// getFileViper initializes a new Viper instance merging the main config with the file based config
-func getFileViper(fileName string) (*viper.Viper, error) {
-	var v *viper.Viper
-	var err error
+// finishSources deletes or moves the successfully imported files from their source, as configured
+func finishSources(ctx context.Context, fetched []fetchedSource, statuses []csv2table.ImportFileStatus) {
+	_, files := csv2table.FileStatuses(statuses)
 
-	// try load global config
-	v, err = getGlobalViper()
-	if err != nil {
-		return nil, err
+	// statuses are looked up by absolute path, the downloaded files may be referenced differently
+	imported := make(map[string]bool)
+	for path, status := range files {
+		if status.Status == csv2table.StatusImported || status.Status == csv2table.StatusSkipped {
+			imported[absPath(path)] = true
+		}
 	}
 
-	if !hasConfigFile(fileName) {
-		return nil, nil
-	}
+	for _, f := range fetched {
+		if f.config.After == "" {
+			continue
+		}
 
-	if v == nil {
-		v = viper.New()
-	}
+		var src csv2table.Source
+		for _, file := range f.files {
+			if !imported[absPath(file.Path)] {
+				continue
+			}
 
-	configFile := getConfigFileName(fileName)
-	v.SetConfigFile(configFile)
+			// the connection is opened again, the import may have taken longer than the server timeout
+			if src == nil {
+				var err error
+				src, err = source.New(f.config)
+				if err != nil {
+					log.Printf("unable to connect to %s source, %v", f.config.Type, err)
+					src = nil
+					break
+				}
+			}
 
-	err = v.MergeInConfig()
-	if err != nil {
-		return nil, fmt.Errorf("unable to read config file (%s), %v", configFile, err)
-	}
+			err := csv2table.FinishFetched(ctx, src, f.config, file)
+			if err != nil {
+				log.Printf("unable to %s remote file %s, %v", f.config.After, file.Name, err)
+			}
+		}
 
-	return v, nil
+		if src != nil {
+			src.Close()
+		}
+	}
 }
 
-// getConfigFileName returns the matching config file name of a csv
-func getConfigFileName(fileName string) string {
-	return strings.Replace(fileName, ".csv", "", -1) + ".toml"
-}
+// absPath returns the absolute path of a file, or the path itself if it can't be determined
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
 
-// hasConfigFile checks wether a csv file has an matching configuration file
-func hasConfigFile(fileName string) bool {
-	_, err := os.Stat(getConfigFileName(fileName))
-	return err == nil
+	return abs
 }
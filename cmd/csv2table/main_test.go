@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/schiorean/csv2table"
+)
+
+func TestFailedFiles(t *testing.T) {
+	statuses := []csv2table.ImportFileStatus{{FileName: "a.csv"}, {FileName: "b.csv"}, {FileName: "c.csv"}}
+	assert.Equal(t, failedFiles(statuses), 0)
+
+	statuses[1].SetError(errors.New("rejected"))
+	statuses[2].SetError(context.Canceled)
+	assert.Equal(t, failedFiles(statuses), 2)
+}
+
+func TestCommandUsage(t *testing.T) {
+	// a missing file is a usage error, returned instead of exiting
+	ctx := context.Background()
+	assert.Equal(t, Import(ctx, nil), 2)
+	assert.Equal(t, Export(ctx, []string{"a.csv", "b.csv"}), 2)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/schiorean/csv2table"
+)
+
+// pendingFile is a file waiting to be stable before being imported
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	changed time.Time // last time a change was noticed
+	marked  bool      // the marker file exists
+}
+
+// pendingFiles holds the files waiting to be imported in watch mode. A file is ready once its size and
+// modification time didn't change for stable or, if marker is set, once its marker file exists.
+type pendingFiles struct {
+	stable time.Duration
+	marker string
+	now    func() time.Time // clock, replaced by the tests
+	files  map[string]*pendingFile
+}
+
+// newPendingFiles creates the pending files of the watch options of config
+func newPendingFiles(config csv2table.Config) *pendingFiles {
+	return &pendingFiles{
+		stable: config.WatchStable,
+		marker: config.WatchMarker,
+		now:    time.Now,
+		files:  make(map[string]*pendingFile),
+	}
+}
+
+// len returns the number of pending files
+func (p *pendingFiles) len() int {
+	return len(p.files)
+}
+
+// notice handles a change of a file: a marker file marks its csv file, other input files become pending
+func (p *pendingFiles) notice(path string) {
+	if p.marker != "" && strings.HasSuffix(path, p.marker) {
+		p.touch(strings.TrimSuffix(path, p.marker))
+		return
+	}
+
+	if csv2table.IsInputFile(path) {
+		p.touch(path)
+	}
+}
+
+// touch adds a file to the pending files, or records a change of a pending file
+func (p *pendingFiles) touch(path string) {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	file, exists := p.files[path]
+	if !exists {
+		file = &pendingFile{}
+		p.files[path] = file
+	}
+
+	file.size = info.Size()
+	file.modTime = info.ModTime()
+	file.changed = p.now()
+	if p.marker != "" {
+		_, err = os.Stat(path + p.marker)
+		file.marked = err == nil
+	}
+}
+
+// remove removes a file from the pending files, e.g. when it is deleted or renamed
+func (p *pendingFiles) remove(path string) {
+	delete(p.files, filepath.Clean(path))
+}
+
+// ready removes the stable files from the pending files and returns them.
+// The files deleted in the meantime are forgotten, the files still being written stay pending.
+func (p *pendingFiles) ready() map[string]bool {
+	now := p.now()
+	ready := make(map[string]bool)
+
+	for path, file := range p.files {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(p.files, path)
+			continue
+		}
+
+		// still being written
+		if info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+			p.touch(path)
+			continue
+		}
+
+		if p.marker != "" {
+			if file.marked {
+				ready[path] = true
+			}
+		} else if now.Sub(file.changed) >= p.stable {
+			ready[path] = true
+		}
+	}
+
+	for path := range ready {
+		delete(p.files, path)
+	}
+
+	return ready
+}
+
+// consumeMarkers deletes the marker files of the imported files
+func (p *pendingFiles) consumeMarkers(files map[string]bool) {
+	if p.marker == "" {
+		return
+	}
+
+	for path := range files {
+		os.Remove(path + p.marker)
+	}
+}
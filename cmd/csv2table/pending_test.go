@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/schiorean/csv2table"
+)
+
+// testClock is a clock moved forward by the tests
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestPendingFiles(config csv2table.Config) (*pendingFiles, *testClock) {
+	clock := &testClock{now: time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)}
+	p := newPendingFiles(config)
+	p.now = clock.Now
+
+	return p, clock
+}
+
+func TestPendingFilesStable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orders.csv")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("id\n1\n"), 0644))
+
+	config := csv2table.NewConfig()
+	config.WatchStable = 10 * time.Second
+	p, clock := newTestPendingFiles(config)
+
+	p.notice(path)
+	p.notice(filepath.Join(dir, "notes.txt"))
+	assert.Equal(t, p.len(), 1)
+
+	clock.now = clock.now.Add(5 * time.Second)
+	assert.Equal(t, len(p.ready()), 0)
+
+	// still being written, the stable time starts again
+	assert.Nil(t, ioutil.WriteFile(path, []byte("id\n1\n2\n"), 0644))
+	clock.now = clock.now.Add(6 * time.Second)
+	assert.Equal(t, len(p.ready()), 0)
+	clock.now = clock.now.Add(9 * time.Second)
+	assert.Equal(t, len(p.ready()), 0)
+
+	clock.now = clock.now.Add(time.Second)
+	assert.Equal(t, p.ready(), map[string]bool{path: true})
+	assert.Equal(t, p.len(), 0)
+
+	// deleted before being stable
+	p.notice(path)
+	assert.Nil(t, os.Remove(path))
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal(t, len(p.ready()), 0)
+	assert.Equal(t, p.len(), 0)
+
+	// renamed away
+	assert.Nil(t, ioutil.WriteFile(path, []byte("id\n1\n"), 0644))
+	p.notice(path)
+	p.remove(path)
+	assert.Equal(t, p.len(), 0)
+}
+
+func TestPendingFilesMarker(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orders.csv")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("id\n1\n"), 0644))
+
+	config := csv2table.NewConfig()
+	config.WatchMarker = ".done"
+	p, clock := newTestPendingFiles(config)
+
+	// the stable time doesn't apply, the marker is waited for
+	p.notice(path)
+	clock.now = clock.now.Add(time.Hour)
+	assert.Equal(t, len(p.ready()), 0)
+
+	assert.Nil(t, ioutil.WriteFile(path+".done", nil, 0644))
+	p.notice(path + ".done")
+	ready := p.ready()
+	assert.Equal(t, ready, map[string]bool{path: true})
+
+	p.consumeMarkers(ready)
+	assert.NoFileExists(t, path+".done")
+	assert.FileExists(t, path)
+
+	// a marker already present when the file is noticed
+	other := filepath.Join(dir, "sales.csv")
+	assert.Nil(t, ioutil.WriteFile(other, []byte("id\n1\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(other+".done", nil, 0644))
+	p.notice(other)
+	assert.Equal(t, p.ready(), map[string]bool{other: true})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/schiorean/csv2table"
+)
+
+// Validate checks the configuration without importing anything: the global configuration file and the
+// configuration of the files, the csv files of the working directory that have a configuration file if none
+// is given. The errors are printed with their file, key and line. It returns the exit status of the process, 1 if
+// any error is found.
+func Validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFile := flags.String("config", "", "configuration file of the files, instead of their own configuration file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: csv2table validate [--config file.toml] [file...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	im, err := newImporter()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	var inputs []csv2table.Input
+	if flags.NArg() == 0 {
+		inputs, err = im.ScanDir(".")
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+	for _, name := range flags.Args() {
+		found, err := csv2table.FileInputs(name)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+
+		for i := range found {
+			found[i].Config = *configFile
+		}
+		inputs = append(inputs, found...)
+	}
+
+	errs := im.Validate(inputs...)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+
+	log.Printf("configuration is valid, %d files checked", len(inputs))
+	return 0
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/schiorean/csv2table"
+)
+
+// watcher holds the state of the watch mode
+type watcher struct {
+	im       *csv2table.Importer
+	config   csv2table.Config
+	fs       *fsnotify.Watcher
+	dir      string // watched directory
+	pending  *pendingFiles
+	imported int // files imported since start
+}
+
+// Watch imports the csv files of a directory as they arrive, until ctx is cancelled.
+// A file is imported once it is stable: its size didn't change for Config.WatchStable or,
+// if Config.WatchMarker is set, its marker file exists (e.g. data.csv.done for data.csv).
+// The configuration files are searched in the watched directory, like for Run. It returns the exit status
+// of the process, 1 when the directory can't be watched or imported anymore.
+func Watch(ctx context.Context, directory string) int {
+	err := os.Chdir(directory)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	im, err := newImporter()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	config := im.Config()
+
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	defer fs.Close()
+
+	w := &watcher{
+		im:      im,
+		config:  config,
+		fs:      fs,
+		dir:     ".",
+		pending: newPendingFiles(config),
+	}
+
+	err = w.addDir(w.dir)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	// files already present are imported too
+	inputs, err := csv2table.ScanDir(w.dir, config)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	for _, in := range inputs {
+		w.pending.touch(in.Path)
+	}
+
+	log.Printf("watching %s", directory)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	if config.WatchHeartbeat <= 0 {
+		config.WatchHeartbeat = csv2table.NewConfig().WatchHeartbeat
+	}
+	heartbeat := time.NewTicker(config.WatchHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("stopped watching %s", directory)
+			return 0
+		case event, ok := <-fs.Events:
+			if !ok {
+				log.Printf("stopped watching %s, the watcher was closed", directory)
+				return 1
+			}
+			w.event(event)
+		case err, ok := <-fs.Errors:
+			if !ok {
+				log.Printf("stopped watching %s, the watcher was closed", directory)
+				return 1
+			}
+			log.Printf("watch error, %v", err)
+		case <-heartbeat.C:
+			log.Print(w.heartbeat(directory))
+		case <-ticker.C:
+			err := w.importReady(ctx)
+			if err != nil {
+				log.Print(err)
+				return 1
+			}
+		}
+	}
+}
+
+// addDir watches a directory, and its subdirectories if Config.Recursive is set
+func (w *watcher) addDir(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && (!w.config.Recursive || strings.HasPrefix(info.Name(), ".")) {
+			return filepath.SkipDir
+		}
+
+		return w.fs.Add(path)
+	})
+}
+
+// event handles a file system event
+func (w *watcher) event(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		w.pending.remove(path)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	if info.IsDir() {
+		if event.Op&fsnotify.Create != 0 && w.config.Recursive {
+			err = w.addDir(path)
+			if err != nil {
+				log.Printf("unable to watch %s, %v", path, err)
+			}
+		}
+		return
+	}
+
+	w.pending.notice(path)
+}
+
+// heartbeat returns the heartbeat log message
+func (w *watcher) heartbeat(directory string) string {
+	return fmt.Sprintf("watching %s, %v file(s) pending, %v file(s) imported", directory, w.pending.len(), w.imported)
+}
+
+// importReady imports the pending files that are stable. The failed files are logged by the importer, an error
+// is returned only if no file can be imported anymore, e.g. when the state file can't be read.
+func (w *watcher) importReady(ctx context.Context) error {
+	ready := w.pending.ready()
+	if len(ready) == 0 {
+		return nil
+	}
+
+	// same selection as Run: includeFiles/exclude patterns, file patterns and configuration files
+	all, err := w.im.ScanDir(w.dir)
+	if err != nil {
+		log.Printf("unable to scan files, %v", err)
+		return nil
+	}
+
+	var inputs []csv2table.Input
+	for _, in := range all {
+		if ready[filepath.Clean(in.Path)] {
+			inputs = append(inputs, in)
+		}
+	}
+
+	if len(inputs) > 0 {
+		statuses, err := w.im.ImportInputs(ctx, inputs)
+		if err != nil {
+			if statuses == nil {
+				return err
+			}
+			log.Print(err)
+		}
+
+		for _, status := range statuses {
+			if status.Status == csv2table.StatusImported {
+				w.imported++
+			}
+		}
+	}
+
+	// markers are consumed by the import
+	w.pending.consumeMarkers(ready)
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/schiorean/csv2table"
+)
+
+// failingService is a DbService failing the files imported into the table "bad"
+type failingService struct {
+	table string
+}
+
+func (s *failingService) Start(fileName string, v *viper.Viper) error {
+	s.table = v.GetString("table")
+	return nil
+}
+
+func (s *failingService) End() error {
+	return nil
+}
+
+func (s *failingService) ProcessHeader(header []string) error {
+	return nil
+}
+
+func (s *failingService) ProcessLine(line []string) error {
+	if s.table == "bad" {
+		return errors.New("rejected")
+	}
+	return nil
+}
+
+func TestWatcherImportReady(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"orders.csv":      "id\n1\n",
+		"orders.toml":     "",
+		"orders.csv.done": "",
+		"broken.csv":      "id\n1\n",
+		"broken.toml":     "table = \"bad\"",
+		"broken.csv.done": "",
+		"sales.csv":       "id\n1\n",
+		"sales.toml":      "",
+	} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	im, err := csv2table.NewImporter(csv2table.WithBackend(func() csv2table.DbService { return &failingService{} }))
+	assert.Nil(t, err)
+
+	config := csv2table.NewConfig()
+	config.WatchMarker = ".done"
+	w := &watcher{im: im, config: config, dir: dir, pending: newPendingFiles(config)}
+	for _, name := range []string{"orders.csv", "broken.csv", "sales.csv"} {
+		w.pending.notice(filepath.Join(dir, name))
+	}
+
+	assert.Nil(t, w.importReady(context.Background()))
+
+	// only the successful import is counted, sales.csv waits for its marker
+	assert.Equal(t, w.imported, 1)
+	assert.Equal(t, w.heartbeat("incoming"), "watching incoming, 1 file(s) pending, 1 file(s) imported")
+	assert.NoFileExists(t, filepath.Join(dir, "orders.csv.done"))
+	assert.NoFileExists(t, filepath.Join(dir, "broken.csv.done"))
+	assert.FileExists(t, filepath.Join(dir, "sales.csv"))
+}
@@ -0,0 +1,178 @@
+package csv2table
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// DirConfigFile is the configuration file of a directory. When scanning a directory, it is inherited by the
+// files of the directory and of its subdirectories, the file configurations overriding it.
+const DirConfigFile = "csv2table.toml"
+
+// configFile is a configuration file with its own settings, without the ones of the files it includes
+type configFile struct {
+	name     string
+	settings map[string]interface{}
+	lines    map[string]int // line of each option, by lower case key, filled by the validation
+}
+
+// readConfigFile reads the settings of a configuration file, merged over the settings of the files it includes
+func readConfigFile(fileName string) (map[string]interface{}, error) {
+	chain, err := readConfigChain(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]interface{})
+	for _, file := range chain {
+		mergeSettings(settings, file.settings)
+	}
+
+	return settings, nil
+}
+
+// readConfigChain reads a configuration file and, recursively, the configuration files of its include option,
+// relative to the file. The included files come first, in merge order. including holds the absolute paths
+// of the files including fileName, to detect include cycles.
+func readConfigChain(fileName string, including ...string) ([]*configFile, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range including {
+		if parent == abs {
+			return nil, fmt.Errorf("%s includes itself", fileName)
+		}
+	}
+
+	settings, includes, err := readConfigSettings(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*configFile
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(fileName), include)
+		}
+
+		included, err := readConfigChain(include, append(including, abs)...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read included config file %s, %v", include, err)
+		}
+		chain = append(chain, included...)
+	}
+
+	return append(chain, &configFile{name: fileName, settings: settings}), nil
+}
+
+// readConfigSettings reads the settings of a single configuration file, expanding the environment variables.
+// The configuration files of the include option are returned apart.
+func readConfigSettings(fileName string) (map[string]interface{}, []string, error) {
+	v := viper.New()
+	v.SetConfigFile(fileName)
+
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	settings := v.AllSettings()
+	err = expandEnv(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var list []interface{}
+	switch include := settings["include"].(type) {
+	case nil:
+	case string:
+		list = []interface{}{include}
+	case []interface{}:
+		list = include
+	default:
+		return nil, nil, fmt.Errorf("include: expected file names, got %v", include)
+	}
+	delete(settings, "include")
+
+	var includes []string
+	for _, item := range list {
+		name, ok := item.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("include: expected a file name, got %v", item)
+		}
+		includes = append(includes, name)
+	}
+
+	return settings, includes, nil
+}
+
+// applyTemplates applies the mapping templates of settings: the options of the template named by the use option
+// of a column mapping are the defaults of its options. The templates are removed from settings once applied.
+func applyTemplates(settings map[string]interface{}) error {
+	templates, _ := settings["templates"].(map[string]interface{})
+	mapping, _ := settings["mapping"].(map[string]interface{})
+
+	for col, value := range mapping {
+		options, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		use, exists := options["use"]
+		if !exists {
+			continue
+		}
+
+		name, _ := use.(string)
+		template, ok := templates[strings.ToLower(name)].(map[string]interface{})
+		if !ok {
+			return &ConfigError{Key: "mapping." + col + ".use", Err: fmt.Errorf("unknown template %v", use)}
+		}
+
+		merged := make(map[string]interface{})
+		mergeSettings(merged, template)
+		mergeSettings(merged, options)
+		delete(merged, "use")
+		mapping[col] = merged
+	}
+
+	delete(settings, "templates")
+	return nil
+}
+
+// dirConfigs returns the directory configuration files inherited by the files of dir, from the scanned
+// directory root down to dir. exists caches the directories already checked.
+func dirConfigs(root string, dir string, exists map[string]bool) []string {
+	var dirs []string
+	for {
+		dirs = append(dirs, dir)
+
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	var files []string
+	for i := len(dirs) - 1; i >= 0; i-- {
+		fileName := filepath.Join(dirs[i], DirConfigFile)
+
+		found, checked := exists[fileName]
+		if !checked {
+			_, err := os.Stat(fileName)
+			found = err == nil
+			exists[fileName] = found
+		}
+
+		if found {
+			files = append(files, fileName)
+		}
+	}
+
+	return files
+}
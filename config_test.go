@@ -0,0 +1,131 @@
+package csv2table
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "common"), 0755))
+	writeFiles(t, dir, map[string]string{
+		"csv2table.toml":     "include = [\"common/db.toml\", \"common/dates.toml\"]\nincludeFiles = [\"incoming/*.csv\", \"exports/*.toml\"]\nhost = \"local\"",
+		"common/db.toml":     "host = \"db\"\nport = 3307\ndb = \"sales\"",
+		"common/dates.toml":  "include = [\"base.toml\"]\nport = 3308",
+		"common/base.toml":   "verbose = true",
+		"loop.toml":          "include = [\"loop2.toml\"]",
+		"loop2.toml":         "include = [\"loop.toml\"]",
+		"only_include.toml":  "include = [\"common/base.toml\"]",
+		"missing_incl.toml":  "include = [\"missing.toml\"]",
+		"single_incl.toml":   "include = \"common/base.toml\"",
+		"invalid_incl.toml":  "include = [1]",
+		"patterns_only.toml": "includeFiles = [\"*.csv\"]",
+	})
+
+	settings, err := readConfigFile(filepath.Join(dir, "csv2table.toml"))
+	assert.Nil(t, err)
+	assert.Equal(t, settings, map[string]interface{}{
+		"includefiles": []interface{}{"incoming/*.csv", "exports/*.toml"},
+		"host":         "local",
+		"port":         int64(3308),
+		"db":           "sales",
+		"verbose":      true,
+	})
+
+	settings, err = readConfigFile(filepath.Join(dir, "only_include.toml"))
+	assert.Nil(t, err)
+	assert.Equal(t, settings, map[string]interface{}{"verbose": true})
+
+	settings, err = readConfigFile(filepath.Join(dir, "single_incl.toml"))
+	assert.Nil(t, err)
+	assert.Equal(t, settings, map[string]interface{}{"verbose": true})
+
+	settings, err = readConfigFile(filepath.Join(dir, "patterns_only.toml"))
+	assert.Nil(t, err)
+	assert.Equal(t, settings, map[string]interface{}{"includefiles": []interface{}{"*.csv"}})
+
+	_, err = readConfigFile(filepath.Join(dir, "loop.toml"))
+	assert.NotNil(t, err)
+	_, err = readConfigFile(filepath.Join(dir, "missing_incl.toml"))
+	assert.NotNil(t, err)
+	_, err = readConfigFile(filepath.Join(dir, "invalid_incl.toml"))
+	assert.NotNil(t, err)
+}
+
+func TestApplyTemplates(t *testing.T) {
+	settings := map[string]interface{}{
+		"templates": map[string]interface{}{
+			"german_date": map[string]interface{}{"type": "DATE NULL DEFAULT NULL", "format": "02.01.2006"},
+		},
+		"mapping": map[string]interface{}{
+			"start_date": map[string]interface{}{"use": "german_date"},
+			"end_date":   map[string]interface{}{"use": "German_Date", "nullif": []interface{}{"31.12.2999"}, "format": "02.01.06"},
+			"amount":     map[string]interface{}{"format": "1,2"},
+		},
+	}
+
+	err := applyTemplates(settings)
+	assert.Nil(t, err)
+	assert.Equal(t, settings, map[string]interface{}{
+		"mapping": map[string]interface{}{
+			"start_date": map[string]interface{}{"type": "DATE NULL DEFAULT NULL", "format": "02.01.2006"},
+			"end_date": map[string]interface{}{"type": "DATE NULL DEFAULT NULL", "format": "02.01.06",
+				"nullif": []interface{}{"31.12.2999"}},
+			"amount": map[string]interface{}{"format": "1,2"},
+		},
+	})
+
+	err = applyTemplates(map[string]interface{}{
+		"mapping": map[string]interface{}{"start_date": map[string]interface{}{"use": "us_date"}},
+	})
+	assert.Equal(t, err.Error(), "mapping.start_date.use: unknown template us_date")
+}
+
+func TestImporterDirConfigs(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "eu", "de"), 0755))
+	writeFiles(t, dir, map[string]string{
+		"csv2table.toml":       "recursive = true\ntable = \"all\"\n[templates.german_date]\nformat = \"02.01.2006\"",
+		"a.csv":                "id;day\n1;01.02.2026\n",
+		"a.toml":               "",
+		"eu/csv2table.toml":    "table = \"eu\"\ndelimiter = \",\"",
+		"eu/b.csv":             "id,day\n2,02.02.2026\n",
+		"eu/b.toml":            "[mapping.day]\nuse = \"german_date\"",
+		"eu/de/csv2table.toml": "delimiter = \"|\"",
+		"eu/de/c.csv":          "id|day\n3|03.02.2026\n",
+		"eu/de/c.toml":         "table = \"de\"",
+	})
+
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	im, err := NewImporter(WithBackend(backend.newService), WithConfigFile(filepath.Join(dir, "csv2table.toml")))
+	assert.Nil(t, err)
+
+	inputs, err := im.ScanDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, len(inputs), 3)
+	assert.Equal(t, inputs[2].DirConfigs, []string{
+		filepath.Join(dir, "csv2table.toml"),
+		filepath.Join(dir, "eu", "csv2table.toml"),
+		filepath.Join(dir, "eu", "de", "csv2table.toml"),
+	})
+
+	// the template of the global configuration is used by the file configuration
+	v, err := im.fileViper(inputs[1])
+	assert.Nil(t, err)
+	assert.Equal(t, v.GetString("mapping.day.format"), "02.01.2006")
+
+	statuses, err := im.ImportInputs(context.Background(), inputs)
+	assert.Nil(t, err)
+	for _, status := range statuses {
+		assert.Nil(t, status.Error)
+	}
+	assert.Equal(t, backend.tables, map[string][][]string{
+		"all": {{"1", "01.02.2026"}},
+		"eu":  {{"2", "02.02.2026"}},
+		"de":  {{"3", "03.02.2026"}},
+	})
+}
@@ -5,7 +5,12 @@
 package csv2table
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -26,26 +31,161 @@ type DbService interface {
 	ProcessLine(line []string) error
 }
 
+// DbServiceContext is the interface implemented by databases that support cancellation.
+// Each method is like its DbService counterpart, stopping as soon as ctx is cancelled.
+type DbServiceContext interface {
+	DbService
+
+	StartContext(ctx context.Context, fileName string, v *viper.Viper) error
+	EndContext(ctx context.Context) error
+	ProcessHeaderContext(ctx context.Context, header []string) error
+	ProcessLineContext(ctx context.Context, line []string) error
+}
+
+// Auditor is the interface implemented by databases that record the import history.
+// Audit is called after End, with the final status of the file.
+type Auditor interface {
+	Audit(ctx context.Context, status ImportFileStatus) error
+}
+
+// Exporter is the interface implemented by databases whose tables can be exported.
+// Export starts reading the rows of the table of v, or of query if it is not empty. The values are
+// formatted the way they are imported, e.g. dates by the format of their column mapping.
+type Exporter interface {
+	Export(ctx context.Context, v *viper.Viper, query string) (RowReader, error)
+}
+
+// RowReader reads the rows of an export
+type RowReader interface {
+	// Columns returns the column names
+	Columns() []string
+
+	// Read returns the next row, nil values are NULL. It returns io.EOF after the last row.
+	Read() ([]*string, error)
+
+	Close() error
+}
+
+// Config holds generic (non db provider) configuration, read from the global configuration file
+type Config struct {
+	ParallelFiles int  // how many files are imported at the same time
+	Strict        bool // validate the configuration before importing, see Importer.Validate
+
+	Sources []SourceConfig // where csv files are fetched from before being imported
+
+	Recursive    bool          // scan subdirectories too
+	IncludeFiles []string      // glob patterns of the files to import, all csv files if empty
+	Exclude      []string      // glob patterns of the files not to import
+	Files        []FilePattern // shared configuration of the files matching a pattern
+
+	SkipUnchanged bool   // skip files already imported with the same content and configuration
+	StateFile     string // file remembering the imported files, used by SkipUnchanged
+
+	ArchiveDir       string // where imported files are moved, {date} is replaced by the current date
+	ErrorDir         string // where files that failed to import are moved, {date} is replaced by the current date
+	ArchiveCompress  bool   // gzip the archived files
+	ArchiveRetention int    // days after which archived files are deleted, 0 keeps them forever
+
+	WatchStable    time.Duration // watch mode: how long a file size must not change before it is imported
+	WatchMarker    string        // watch mode: suffix of the marker file signaling a complete file, e.g. ".done"
+	WatchHeartbeat time.Duration // watch mode: interval of the heartbeat log
+
+	Email Email // email notification
+}
+
+const (
+	defaultParallelFiles = 1
+	defaultStateFile     = "csv2table.state.json"
+
+	defaultWatchStable    = 10 * time.Second
+	defaultWatchHeartbeat = 5 * time.Minute
+)
+
+// NewConfig creates a new Config and applies defaults
+func NewConfig() Config {
+	return Config{
+		ParallelFiles: defaultParallelFiles,
+		StateFile:     defaultStateFile,
+
+		WatchStable:    defaultWatchStable,
+		WatchHeartbeat: defaultWatchHeartbeat,
+
+		Email: newEmail(),
+	}
+}
+
+// import statuses of a file
+const (
+	StatusImported  = "imported"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusSkipped   = "skipped"
+)
+
 // ImportFileStatus holds import status for each imported file
 type ImportFileStatus struct {
 	FileName string // processed filename
+	Path     string // file on disk, differs from FileName for entries of a zip archive
+	Status   string // one of the Status* constants
 	Error    error  // error or nil if success
 	RowCount int    // processed rows
+
+	RunID    string    // identifies the run that imported the file
+	Size     int64     // size in bytes of the (uncompressed) csv
+	Checksum string    // hex encoded SHA-256 of the (uncompressed) csv, empty if not completely read
+	Started  time.Time // import start time
+	Finished time.Time // import end time
+}
+
+// SetError sets the import error and the matching status
+func (s *ImportFileStatus) SetError(err error) {
+	s.Error = err
+
+	if errors.Is(err, context.Canceled) {
+		s.Status = StatusCancelled
+	} else if err != nil {
+		s.Status = StatusFailed
+	} else {
+		s.Status = StatusImported
+	}
+}
+
+// NewRunID creates a random id identifying an import run
+func NewRunID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		// fallback to the current time, still unique enough for a run
+		return time.Now().Format("20060102150405.000000000")
+	}
+
+	return hex.EncodeToString(b)
 }
 
 // UnmarshallConfig reads generic (non db provider) configuration
-func UnmarshallConfig(v *viper.Viper) error {
-	// email configuration
-	err := v.UnmarshalKey("email", &emailConfig)
+func UnmarshallConfig(v *viper.Viper) (Config, error) {
+	config := NewConfig()
+	if v == nil {
+		return config, nil
+	}
+
+	err := v.Unmarshal(&config)
 	if err != nil {
-		return fmt.Errorf("unable to unmarshall loaded configuration email, %v", err)
+		return config, fmt.Errorf("unable to unmarshall loaded configuration, %v", err)
+	}
+
+	if config.Email.PlainAuth.PasswordFile != "" {
+		config.Email.PlainAuth.Password, err = ReadPasswordFile(config.Email.PlainAuth.PasswordFile)
+		if err != nil {
+			return config, err
+		}
 	}
 
-	return nil
+	return config, nil
 }
 
 // AfterImport is called after all files were processed
-func AfterImport(statuses []ImportFileStatus) error {
+func AfterImport(config Config, statuses []ImportFileStatus) error {
 	errors := false
 	var status ImportFileStatus
 
@@ -57,19 +197,19 @@ func AfterImport(statuses []ImportFileStatus) error {
 	}
 
 	// if email is not configured there's nothing else to do
-	if !emailConfigured() {
+	if !config.Email.configured() {
 		return nil
 	}
 
 	var err error
 
 	if !errors {
-		if emailConfig.SendOnSuccess {
-			err = sendEmailSuccess(statuses)
+		if config.Email.SendOnSuccess {
+			err = sendEmailSuccess(config.Email, statuses)
 		}
 	} else {
-		if emailConfig.SendOnError {
-			err = sendEmailError(statuses)
+		if config.Email.SendOnError {
+			err = sendEmailError(config.Email, statuses)
 		}
 	}
 
@@ -32,6 +32,8 @@ type Email struct {
 		Username string
 		Password string
 		Host     string
+
+		PasswordFile string // file holding the password, e.g. a Docker or Kubernetes secret, overrides Password
 	}
 }
 
@@ -39,6 +41,7 @@ type Email struct {
 type EmailTemplateContext struct {
 	SuccessCount int
 	ErrorCount   int
+	SkippedCount int
 	Files        []ImportFileStatus
 }
 
@@ -48,14 +51,14 @@ const (
 )
 
 // subject templates
-// available variables: {{SuccessCount}, {{ErrorCount}}
+// available variables: {{SuccessCount}, {{ErrorCount}}, {{SkippedCount}}
 const (
 	defaultSuccessSubject = "csv2table: imported {{.SuccessCount}} file(s)"
 	defaultErrorSubject   = "csv2table: import errors"
 )
 
 // emial body templates
-// available variables: {{SuccessCount}}, {{ErrorCount}, {{Files}}
+// available variables: {{SuccessCount}}, {{ErrorCount}, {{SkippedCount}}, {{Files}}
 const (
 	defaultSuccessBody = `
 Hello,<br/><br/>
@@ -66,11 +69,18 @@ Hello,<br/><br/>
 {{if .ErrorCount}}
 	<span style="color:red">{{.ErrorCount}} file(s) produced errors.</span><br/>
 {{end}}	
+{{if .SkippedCount}}
+	Skipped {{.SkippedCount}} unchanged file(s).<br/>
+{{end}}	
 
 <ol>
 {{range .Files}}
 	<li>
-		{{if .Error}}
+		{{if eq .Status "cancelled"}}
+			{{.FileName}}: Cancelled after {{.RowCount}} rows
+		{{else if eq .Status "skipped"}}
+			{{.FileName}}: Skipped, unchanged since last import
+		{{else if .Error}}
 			{{.FileName}}: Error: {{.Error}}
 		{{else}}
 			{{.FileName}}: Imported {{.RowCount}} rows
@@ -84,30 +94,34 @@ Bye.
 	defaultErrorBody = defaultSuccessBody
 )
 
-// emailConfig is the global email configuration
-var emailConfig = Email{
-	SendOnSuccess:  defaultSendOnSuccess,
-	SendOnError:    defaultSendOnError,
-	SuccessSubject: defaultSuccessSubject,
-	SuccessBody:    defaultSuccessBody,
-	ErrorSubject:   defaultErrorSubject,
-	ErrorBody:      defaultErrorBody,
+// newEmail creates a new Email configuration and applies defaults
+func newEmail() Email {
+	return Email{
+		SendOnSuccess:  defaultSendOnSuccess,
+		SendOnError:    defaultSendOnError,
+		SuccessSubject: defaultSuccessSubject,
+		SuccessBody:    defaultSuccessBody,
+		ErrorSubject:   defaultErrorSubject,
+		ErrorBody:      defaultErrorBody,
+	}
 }
 
-// emailConfigured checks if email configuration is present
-func emailConfigured() bool {
-	return emailConfig.SMTPServer != "" && emailConfig.From != "" && len(emailConfig.To) > 0
+// configured checks if email configuration is present
+func (e Email) configured() bool {
+	return e.SMTPServer != "" && e.From != "" && len(e.To) > 0
 }
 
 // createTemplateContext creates the template context used in subject and body parsing
 func createTemplateContext(statuses []ImportFileStatus) EmailTemplateContext {
 
-	var successCount, errorCount int
+	var successCount, errorCount, skippedCount int
 	var status ImportFileStatus
 
 	for _, status = range statuses {
 		if status.Error != nil {
 			errorCount++
+		} else if status.Status == StatusSkipped {
+			skippedCount++
 		} else {
 			successCount++
 		}
@@ -116,6 +130,7 @@ func createTemplateContext(statuses []ImportFileStatus) EmailTemplateContext {
 	return EmailTemplateContext{
 		SuccessCount: successCount,
 		ErrorCount:   errorCount,
+		SkippedCount: skippedCount,
 		Files:        statuses,
 	}
 }
@@ -138,7 +153,7 @@ func parseTemplate(tpl string, context EmailTemplateContext) (string, error) {
 }
 
 // sendEmailSuccess delivers a success email after an import successfully finished
-func sendEmailSuccess(statuses []ImportFileStatus) error {
+func sendEmailSuccess(emailConfig Email, statuses []ImportFileStatus) error {
 	e := &email.Email{
 		To:      emailConfig.To,
 		From:    emailConfig.From,
@@ -172,7 +187,7 @@ func sendEmailSuccess(statuses []ImportFileStatus) error {
 }
 
 // sendEmailError delivers a success email after an import successfully finished
-func sendEmailError(statuses []ImportFileStatus) error {
+func sendEmailError(emailConfig Email, statuses []ImportFileStatus) error {
 	e := &email.Email{
 		To:      emailConfig.To,
 		From:    emailConfig.From,
@@ -0,0 +1,102 @@
+package csv2table
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// envPrefix is the prefix of the environment variables overriding the configuration,
+// e.g. CSV2TABLE_PASSWORD overrides password and CSV2TABLE_EMAIL_PLAINAUTH_PASSWORD overrides email.plainAuth.password
+const envPrefix = "CSV2TABLE"
+
+// envRef matches the ${NAME} references to environment variables in configuration values
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${NAME} references to environment variables in the string values of settings,
+// including the values of lists and nested settings. An unset variable is an error, an empty one is not.
+func expandEnv(settings map[string]interface{}) error {
+	for key, value := range settings {
+		expanded, err := expandEnvValue(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		settings[key] = expanded
+	}
+
+	return nil
+}
+
+// expandEnvValue expands the environment variables of a setting value
+func expandEnvValue(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		var err error
+		expanded := envRef.ReplaceAllStringFunc(value, func(ref string) string {
+			name := envRef.FindStringSubmatch(ref)[1]
+			env, found := os.LookupEnv(name)
+			if !found && err == nil {
+				err = fmt.Errorf("environment variable %s is not set", name)
+			}
+			return env
+		})
+		return expanded, err
+
+	case map[string]interface{}:
+		return value, expandEnv(value)
+
+	case []interface{}:
+		for i := range value {
+			expanded, err := expandEnvValue(value[i])
+			if err != nil {
+				return nil, err
+			}
+			value[i] = expanded
+		}
+		return value, nil
+
+	case []map[string]interface{}:
+		for _, m := range value {
+			err := expandEnv(m)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+
+	return value, nil
+}
+
+// bindEnv makes the CSV2TABLE_ environment variables override the settings of v. Besides AutomaticEnv,
+// the variables that are set are bound to their keys, so that Unmarshal sees them too.
+func bindEnv(v *viper.Viper) {
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !strings.HasPrefix(name, envPrefix+"_") {
+			continue
+		}
+
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, envPrefix+"_"), "_", "."))
+		v.BindEnv(key)
+	}
+}
+
+// ReadPasswordFile reads a password from a file, e.g. a Docker or Kubernetes secret.
+// The line break ending the file, if any, is not part of the password.
+func ReadPasswordFile(fileName string) (string, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", fmt.Errorf("unable to read password file, %v", err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
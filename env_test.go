@@ -0,0 +1,69 @@
+package csv2table
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "db.local")
+	t.Setenv("EMPTY", "")
+
+	settings := map[string]interface{}{
+		"host":   "${DB_HOST}:3306",
+		"port":   3306,
+		"empty":  "[${EMPTY}]",
+		"dollar": "$DB_HOST",
+		"email":  map[string]interface{}{"to": []interface{}{"ops@${DB_HOST}"}},
+	}
+	err := expandEnv(settings)
+	assert.Nil(t, err)
+	assert.Equal(t, settings, map[string]interface{}{
+		"host":   "db.local:3306",
+		"port":   3306,
+		"empty":  "[]",
+		"dollar": "$DB_HOST",
+		"email":  map[string]interface{}{"to": []interface{}{"ops@db.local"}},
+	})
+
+	// unset variables
+	err = expandEnv(map[string]interface{}{"password": "${CSV2TABLE_TEST_UNSET}"})
+	assert.NotNil(t, err)
+}
+
+func TestImporterEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"csv2table.toml": "db = \"${TEST_DB}\"\n[email.plainAuth]\nusername = \"ops\"\npasswordFile = \"" +
+			filepath.ToSlash(filepath.Join(dir, "smtp_password")) + "\"",
+		"smtp_password": "secret\n",
+		"people.toml":   "table = \"${TEST_TABLE}\"",
+	})
+	t.Setenv("TEST_DB", "warehouse")
+	t.Setenv("TEST_TABLE", "persons")
+	t.Setenv("CSV2TABLE_EMAIL_PLAINAUTH_USERNAME", "admin")
+
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	im, err := NewImporter(WithBackend(backend.newService), WithConfigFile(filepath.Join(dir, "csv2table.toml")))
+	assert.Nil(t, err)
+	assert.Equal(t, im.Config().Email.PlainAuth.Username, "admin")
+	assert.Equal(t, im.Config().Email.PlainAuth.Password, "secret")
+
+	v, err := im.fileViper(Input{Name: "people.csv", Path: filepath.Join(dir, "people.csv")})
+	assert.Nil(t, err)
+	assert.Equal(t, v.GetString("db"), "warehouse")
+	assert.Equal(t, v.GetString("table"), "persons")
+
+	// the environment overrides the file configuration
+	t.Setenv("CSV2TABLE_TABLE", "staff")
+	v, err = im.fileViper(Input{Name: "people.csv", Path: filepath.Join(dir, "people.csv")})
+	assert.Nil(t, err)
+	assert.Equal(t, v.GetString("table"), "staff")
+
+	// a missing password file
+	writeFiles(t, dir, map[string]string{"csv2table.toml": "[email.plainAuth]\npasswordFile = \"missing\""})
+	_, err = NewImporter(WithBackend(backend.newService), WithConfigFile(filepath.Join(dir, "csv2table.toml")))
+	assert.NotNil(t, err)
+}
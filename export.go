@@ -0,0 +1,110 @@
+package csv2table
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+
+	"github.com/spf13/viper"
+	"golang.org/x/text/transform"
+)
+
+// Export writes the rows of a table as csv to w, the reverse of the import of in: the configuration is
+// the global configuration merged with the configuration file of in, if any, and the table is the one in
+// is imported into. If in has no name, the global configuration is used, in.Table being the table.
+// If query is not empty its rows are exported instead. It returns the number of rows written.
+func (im *Importer) Export(ctx context.Context, in Input, w io.Writer, query string) (int, error) {
+	exporter, ok := im.newService().(Exporter)
+	if !ok {
+		return 0, errors.New("the database doesn't support exports")
+	}
+
+	v := im.globalViper()
+	if in.Name != "" {
+		var err error
+		v, err = im.fileViper(in)
+		if err != nil {
+			return 0, err
+		}
+
+		// the default table of the file
+		if v.GetString("table") == "" {
+			v.Set("table", DefaultTableName(in.Name))
+		}
+	} else if in.Table != "" {
+		v.Set("table", in.Table)
+	}
+
+	rr, err := exporter.Export(ctx, v, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rr.Close()
+
+	return ExportCsv(w, rr, v)
+}
+
+// ExportCsv writes the rows read from rr as csv to w, the column names first. The delimiter and encoding
+// options of v are applied, NULL values are written as the nullToken option, empty by default.
+// It returns the number of rows written, not counting the header.
+func ExportCsv(w io.Writer, rr RowReader, v *viper.Viper) (int, error) {
+	delimiter, err := csvDelimiter(v)
+	if err != nil {
+		return 0, err
+	}
+
+	enc, err := textEncoding(v)
+	if err != nil {
+		return 0, err
+	}
+	var encoder *transform.Writer
+	if enc != nil {
+		encoder = transform.NewWriter(w, enc.NewEncoder())
+		w = encoder
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+
+	err = cw.Write(rr.Columns())
+	if err != nil {
+		return 0, err
+	}
+
+	nullToken := v.GetString("nullToken")
+	rows := 0
+	for {
+		row, err := rr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+
+		record := make([]string, len(row))
+		for i, value := range row {
+			if value == nil {
+				record[i] = nullToken
+			} else {
+				record[i] = *value
+			}
+		}
+
+		err = cw.Write(record)
+		if err != nil {
+			return rows, err
+		}
+		rows++
+	}
+
+	cw.Flush()
+	err = cw.Error()
+	if err == nil && encoder != nil {
+		// the encoder holds the end of an incomplete character
+		err = encoder.Close()
+	}
+
+	return rows, err
+}
@@ -0,0 +1,131 @@
+package csv2table
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceRows is a RowReader of rows held in memory
+type sliceRows struct {
+	columns []string
+	rows    [][]*string
+	closed  bool
+}
+
+func (r *sliceRows) Columns() []string {
+	return r.columns
+}
+
+func (r *sliceRows) Read() ([]*string, error) {
+	if len(r.rows) == 0 {
+		return nil, io.EOF
+	}
+
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	return row, nil
+}
+
+func (r *sliceRows) Close() error {
+	r.closed = true
+	return nil
+}
+
+// exportService is a DbService exporting the rows of its configured table
+type exportService struct {
+	tableService
+
+	tables  map[string][][]*string
+	exports []string // exported tables
+}
+
+func (s *exportService) Export(ctx context.Context, v *viper.Viper, query string) (RowReader, error) {
+	table := v.GetString("table")
+	s.exports = append(s.exports, table)
+
+	return &sliceRows{columns: []string{"id", "name"}, rows: s.tables[table]}, nil
+}
+
+// str returns a pointer to a string
+func str(s string) *string {
+	return &s
+}
+
+func TestExportCsv(t *testing.T) {
+	rows := &sliceRows{
+		columns: []string{"id", "name"},
+		rows:    [][]*string{{str("1"), str("Bäcker; Ana")}, {str("2"), nil}, {str("3"), str("")}},
+	}
+
+	v := viper.New()
+	v.Set("nullToken", `\N`)
+
+	var buf bytes.Buffer
+	n, err := ExportCsv(&buf, rows, v)
+	assert.Nil(t, err)
+	assert.Equal(t, n, 3)
+	assert.Equal(t, buf.String(), "id;name\n1;\"Bäcker; Ana\"\n2;\\N\n3;\n")
+}
+
+func TestExportCsvEncoding(t *testing.T) {
+	rows := &sliceRows{columns: []string{"name"}, rows: [][]*string{{str("Bäcker")}}}
+
+	v := viper.New()
+	v.Set("delimiter", "\t")
+	v.Set("encoding", "windows-1252")
+
+	var buf bytes.Buffer
+	_, err := ExportCsv(&buf, rows, v)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.Bytes(), []byte("name\nB\xe4cker\n"))
+
+	// characters missing from the encoding
+	rows = &sliceRows{columns: []string{"name"}, rows: [][]*string{{str("Ș")}}}
+	_, err = ExportCsv(&bytes.Buffer{}, rows, v)
+	assert.NotNil(t, err)
+
+	v.Set("delimiter", ";;")
+	_, err = ExportCsv(&bytes.Buffer{}, rows, v)
+	assert.NotNil(t, err)
+}
+
+func TestImporterExport(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"people.toml": "table = \"persons\"\ndelimiter = \",\"\nnullToken = \"NULL\"",
+	})
+
+	service := &exportService{tables: map[string][][]*string{
+		"persons": {{str("1"), str("Ana")}, {str("2"), nil}},
+	}}
+	im, err := NewImporter(WithBackend(func() DbService { return service }))
+	assert.Nil(t, err)
+
+	// the configuration file of the csv file is used, as for its import
+	var buf bytes.Buffer
+	in := Input{Name: "people.csv", Path: filepath.Join(dir, "people.csv")}
+	n, err := im.Export(context.Background(), in, &buf, "")
+	assert.Nil(t, err)
+	assert.Equal(t, n, 2)
+	assert.Equal(t, buf.String(), "id,name\n1,Ana\n2,NULL\n")
+
+	// the default table of a csv file, or the table set explicitly
+	_, err = im.Export(context.Background(), Input{Name: "other.csv", Path: filepath.Join(dir, "other.csv")}, &buf, "")
+	assert.Nil(t, err)
+	_, err = im.Export(context.Background(), Input{Table: "stock"}, &buf, "")
+	assert.Nil(t, err)
+	assert.Equal(t, service.exports, []string{"persons", "other", "stock"})
+
+	// the backend must support exports
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	im, err = NewImporter(WithBackend(backend.newService))
+	assert.Nil(t, err)
+	_, err = im.Export(context.Background(), in, &buf, "")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,156 @@
+package csv2table
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/viper"
+)
+
+// trim options of the fixed width fields
+const (
+	TrimBoth  = "both"
+	TrimLeft  = "left"
+	TrimRight = "right"
+	TrimNone  = "none"
+)
+
+// maxFixedLine is the maximum length of a fixed width record
+const maxFixedLine = 1024 * 1024
+
+// FixedField declares a field of a fixed width record
+type FixedField struct {
+	Name  string // column name
+	Start int    // position of the first character, starting at 1, right after the previous field if 0
+	Width int    // number of characters
+}
+
+// fixedReader reads fixed width records, one per line. Positions and widths are in characters, not bytes.
+type fixedReader struct {
+	scanner *bufio.Scanner
+	fields  []FixedField
+	names   []string // declared names, returned as header
+	trim    string
+}
+
+// newFixedReader creates a fixed width reader from the fields option, or from the widths option.
+// Without declared names, the first record is the header.
+func newFixedReader(r io.Reader, v *viper.Viper, skipRows int) (*fixedReader, error) {
+	var fields []FixedField
+	err := v.UnmarshalKey("fields", &fields)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read fixed width fields, %v", err)
+	}
+
+	var names []string
+	if len(fields) > 0 {
+		for _, field := range fields {
+			names = append(names, field.Name)
+		}
+	} else {
+		names = v.GetStringSlice("names")
+		for _, width := range v.GetIntSlice("widths") {
+			fields = append(fields, FixedField{Width: width})
+		}
+		if len(names) > 0 && len(names) != len(fields) {
+			return nil, fmt.Errorf("%d names declared for %d widths", len(names), len(fields))
+		}
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("fields or widths are required for fixed width files")
+	}
+
+	// resolve the consecutive fields
+	next := 1
+	for i := range fields {
+		if fields[i].Start == 0 {
+			fields[i].Start = next
+		}
+		if fields[i].Start < 1 || fields[i].Width < 1 {
+			return nil, fmt.Errorf("invalid fixed width field %d, start and width must be positive", i+1)
+		}
+		next = fields[i].Start + fields[i].Width
+	}
+
+	trim := strings.ToLower(v.GetString("trim"))
+	switch trim {
+	case "":
+		trim = TrimBoth
+	case TrimBoth, TrimLeft, TrimRight, TrimNone:
+	default:
+		return nil, fmt.Errorf("invalid trim option %s, expecting %s, %s, %s or %s", trim, TrimBoth, TrimLeft, TrimRight, TrimNone)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxFixedLine)
+	for i := 0; i < skipRows; i++ {
+		if !scanner.Scan() {
+			break
+		}
+	}
+
+	return &fixedReader{scanner: scanner, fields: fields, names: names, trim: trim}, nil
+}
+
+// Read returns the declared names first, then the fields of the next non blank line
+func (f *fixedReader) Read() ([]string, error) {
+	if f.names != nil {
+		names := f.names
+		f.names = nil
+		return names, nil
+	}
+
+	for f.scanner.Scan() {
+		line := strings.TrimRight(f.scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		return f.split([]rune(line)), nil
+	}
+
+	err := f.scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+
+	return nil, err
+}
+
+// split cuts a line into its fields, missing characters at the end of a short line are empty
+func (f *fixedReader) split(line []rune) []string {
+	record := make([]string, len(f.fields))
+	for i, field := range f.fields {
+		start := field.Start - 1
+		if start >= len(line) {
+			continue
+		}
+
+		end := start + field.Width
+		if end > len(line) {
+			end = len(line)
+		}
+
+		record[i] = f.trimValue(string(line[start:end]))
+	}
+
+	return record
+}
+
+// trimValue removes the padding of a value, as set by the trim option
+func (f *fixedReader) trimValue(value string) string {
+	switch f.trim {
+	case TrimBoth:
+		return strings.TrimSpace(value)
+	case TrimLeft:
+		return strings.TrimLeftFunc(value, unicode.IsSpace)
+	case TrimRight:
+		return strings.TrimRightFunc(value, unicode.IsSpace)
+	}
+
+	return value
+}
@@ -0,0 +1,90 @@
+package csv2table
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll reads all records of a RecordReader
+func readAll(t *testing.T, r RecordReader) [][]string {
+	var records [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records
+		}
+		assert.Nil(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestFixedReaderFields(t *testing.T) {
+	v := viper.New()
+	v.Set("fields", []map[string]interface{}{
+		{"name": "id", "start": 1, "width": 5},
+		{"name": "name", "width": 10},
+		{"name": "amount", "start": 18, "width": 8},
+	})
+
+	data := "00001Ana       XX   12.50\r\n" +
+		"\n" +
+		"00002Bogdănel  XX 1000.00\n" +
+		"00003Short\n"
+
+	r, err := newFixedReader(strings.NewReader(data), v, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, readAll(t, r), [][]string{
+		{"id", "name", "amount"},
+		{"00001", "Ana", "12.50"},
+		{"00002", "Bogdănel", "1000.00"},
+		{"00003", "Short", ""},
+	})
+}
+
+func TestFixedReaderWidths(t *testing.T) {
+	v := viper.New()
+	v.Set("widths", []int{3, 6})
+	v.Set("trim", TrimRight)
+
+	// without names, the first record is the header
+	data := "ID NAME  \n  1 Ana  \n"
+
+	r, err := newFixedReader(strings.NewReader(data), v, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, readAll(t, r), [][]string{
+		{"ID", "NAME"},
+		{"  1", " Ana"},
+	})
+}
+
+func TestFixedReaderSkipRows(t *testing.T) {
+	v := viper.New()
+	v.Set("widths", []int{2, 2})
+	v.Set("names", []string{"a", "b"})
+	v.Set("trim", TrimNone)
+
+	r, err := newFixedReader(strings.NewReader("EXTRACT 2026-10-15\n1 2 \n"), v, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, readAll(t, r), [][]string{{"a", "b"}, {"1 ", "2 "}})
+}
+
+func TestFixedReaderInvalid(t *testing.T) {
+	_, err := newFixedReader(strings.NewReader(""), viper.New(), 0)
+	assert.NotNil(t, err)
+
+	v := viper.New()
+	v.Set("widths", []int{2, 2})
+	v.Set("names", []string{"a"})
+	_, err = newFixedReader(strings.NewReader(""), v, 0)
+	assert.NotNil(t, err)
+
+	v = viper.New()
+	v.Set("widths", []int{2})
+	v.Set("trim", "middle")
+	_, err = newFixedReader(strings.NewReader(""), v, 0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,246 @@
+package csv2table
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// defaultDelimiter is the delimiter of the csv files, unless set by the delimiter option
+const defaultDelimiter = ';'
+
+// supported file formats
+const (
+	FormatCsv     = "csv"
+	FormatXlsx    = "xlsx"
+	FormatXls     = "xls"
+	FormatFixed   = "fixed"
+	FormatJson    = "json"
+	FormatParquet = "parquet"
+)
+
+// formats holds the format of the data files, by extension
+var formats = map[string]string{
+	".csv":     FormatCsv,
+	".xlsx":    FormatXlsx,
+	".xls":     FormatXls,
+	".dat":     FormatFixed,
+	".json":    FormatJson,
+	".jsonl":   FormatJson,
+	".ndjson":  FormatJson,
+	".parquet": FormatParquet,
+}
+
+// data types of the columns of typed formats, e.g. parquet. They are passed to the DbService
+// in the dataTypes option, by column name, the DbService choosing the matching column definitions.
+const (
+	DataTypeString   = "string"
+	DataTypeBinary   = "binary"
+	DataTypeBool     = "bool"
+	DataTypeInt      = "int"    // 32 bit integer
+	DataTypeBigInt   = "bigint" // 64 bit integer
+	DataTypeFloat    = "float"
+	DataTypeDouble   = "double"
+	DataTypeDate     = "date"
+	DataTypeTime     = "time"
+	DataTypeDateTime = "datetime"
+)
+
+// DecimalDataType returns the data type of a decimal column, e.g. decimal(10,2)
+func DecimalDataType(precision int, scale int) string {
+	return fmt.Sprintf("decimal(%d,%d)", precision, scale)
+}
+
+// RecordReader reads the records of a data file. The first record is the header,
+// Read returns io.EOF after the last record.
+type RecordReader interface {
+	Read() ([]string, error)
+}
+
+// typedReader is a RecordReader knowing the data types of its columns, by column name
+type typedReader interface {
+	DataTypes() map[string]string
+}
+
+// inputFormat returns the format of a data file, set by the format option or guessed from its extension.
+// The default format is csv.
+func inputFormat(name string, v *viper.Viper) string {
+	if format := v.GetString("format"); format != "" {
+		return strings.ToLower(format)
+	}
+
+	if format, exists := formats[strings.ToLower(filepath.Ext(trimCompression(name)))]; exists {
+		return format
+	}
+
+	return FormatCsv
+}
+
+// newRecordReader creates the reader of a data format. The skipRows option sets the number of rows
+// skipped before the header, e.g. a title.
+func newRecordReader(format string, r io.Reader, v *viper.Viper) (RecordReader, error) {
+	skipRows := v.GetInt("skipRows")
+
+	switch format {
+	case FormatCsv:
+		delimiter, err := csvDelimiter(v)
+		if err != nil {
+			return nil, err
+		}
+		r, err = decodeText(r, v)
+		if err != nil {
+			return nil, err
+		}
+		return newCsvReader(r, delimiter, skipRows)
+	case FormatXlsx:
+		return newXlsxReader(r, v.GetString("sheet"), v.GetInt("sheetIndex"), skipRows)
+	case FormatXls:
+		return newXlsReader(r, v.GetString("sheet"), v.GetInt("sheetIndex"), skipRows)
+	case FormatFixed:
+		r, err := decodeText(r, v)
+		if err != nil {
+			return nil, err
+		}
+		return newFixedReader(r, v, skipRows)
+	case FormatJson:
+		return newJsonReader(r, v)
+	case FormatParquet:
+		return newParquetReader(r)
+	}
+
+	return nil, fmt.Errorf("unknown format %s", format)
+}
+
+// readerOptions holds the options of the record readers, used to validate the configuration files
+type readerOptions struct {
+	Format   string
+	SkipRows int
+
+	Delimiter string // csv
+	Encoding  string // csv, fixed
+
+	Sheet      string // xlsx, xls
+	SheetIndex int    // xlsx, xls
+
+	Fields []FixedField // fixed
+	Widths []int        // fixed
+	Names  []string     // fixed
+	Trim   string       // fixed
+
+	Columns     []string // json
+	ScanRecords int      // json
+	Arrays      string   // json
+
+	NullToken string // export
+}
+
+// checkReaderOptions checks the values of the reader options of v
+func checkReaderOptions(v *viper.Viper) []*ConfigError {
+	var errs []*ConfigError
+	invalid := func(key string, err error) {
+		errs = append(errs, &ConfigError{Key: key, Err: err})
+	}
+
+	if format := strings.ToLower(v.GetString("format")); format != "" {
+		switch format {
+		case FormatCsv, FormatXlsx, FormatXls, FormatFixed, FormatJson, FormatParquet:
+		default:
+			invalid("format", fmt.Errorf("unknown format %s", format))
+		}
+	}
+
+	if _, err := csvDelimiter(v); err != nil {
+		invalid("delimiter", err)
+	}
+	if _, err := textEncoding(v); err != nil {
+		invalid("encoding", err)
+	}
+
+	for _, key := range []string{"skipRows", "sheetIndex", "scanRecords"} {
+		if v.GetInt(key) < 0 {
+			invalid(strings.ToLower(key), fmt.Errorf("%s can't be negative", key))
+		}
+	}
+
+	switch trim := strings.ToLower(v.GetString("trim")); trim {
+	case "", TrimBoth, TrimLeft, TrimRight, TrimNone:
+	default:
+		invalid("trim", fmt.Errorf("invalid trim option %s, expecting %s, %s, %s or %s", trim, TrimBoth, TrimLeft, TrimRight, TrimNone))
+	}
+
+	switch arrays := strings.ToLower(v.GetString("arrays")); arrays {
+	case "", ArraysString, ArraysExplode:
+	default:
+		invalid("arrays", fmt.Errorf("invalid arrays option %s, expecting %s or %s", arrays, ArraysString, ArraysExplode))
+	}
+
+	return errs
+}
+
+// newCsvReader creates a reader of delimiter separated values
+func newCsvReader(r io.Reader, delimiter rune, skipRows int) (RecordReader, error) {
+	// the skipped lines don't have to be valid csv records
+	br := bufio.NewReader(r)
+	for i := 0; i < skipRows; i++ {
+		_, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = delimiter
+
+	return cr, nil
+}
+
+// csvDelimiter returns the delimiter option, a semicolon by default
+func csvDelimiter(v *viper.Viper) (rune, error) {
+	delimiter := v.GetString("delimiter")
+	if delimiter == "" {
+		return defaultDelimiter, nil
+	}
+
+	d, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || d == utf8.RuneError || d == '"' || d == '\r' || d == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q, expecting a single character", delimiter)
+	}
+
+	return d, nil
+}
+
+// textEncoding returns the encoding option, e.g. windows-1252, or nil for utf-8, the default encoding
+func textEncoding(v *viper.Viper) (encoding.Encoding, error) {
+	name := v.GetString("encoding")
+	if name == "" {
+		return nil, nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %s", name)
+	}
+	if canonical, _ := htmlindex.Name(enc); canonical == "utf-8" {
+		return nil, nil
+	}
+
+	return enc, nil
+}
+
+// decodeText converts the text read from r from the encoding option to utf-8
+func decodeText(r io.Reader, v *viper.Viper) (io.Reader, error) {
+	enc, err := textEncoding(v)
+	if err != nil || enc == nil {
+		return r, err
+	}
+
+	return enc.NewDecoder().Reader(r), nil
+}
@@ -0,0 +1,240 @@
+package csv2table
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// importGroup holds the files imported into the same table. They are processed as one logical import:
+// drop and truncate apply only before the first file, and all files must have the same header.
+type importGroup struct {
+	table string
+	files []*groupFile
+
+	header []string // sanitized header of the first file
+}
+
+// groupFile is a file of an importGroup
+type groupFile struct {
+	in     Input
+	v      *viper.Viper
+	status *ImportFileStatus
+}
+
+// groupFiles reads the configuration of the inputs and groups them by target table, in the order of the inputs.
+// Inputs whose configuration can't be read are not grouped, their status is set to failed.
+func (im *Importer) groupFiles(inputs []Input, runID string, statuses []ImportFileStatus) []*importGroup {
+	var groups []*importGroup
+	tables := make(map[string]*importGroup)
+
+	for i, in := range inputs {
+		statuses[i] = ImportFileStatus{
+			FileName: in.String(),
+			Path:     in.Path,
+			RunID:    runID,
+		}
+
+		v, err := im.fileViper(in)
+		if err == nil && im.config.Strict {
+			if errs := im.Validate(in); len(errs) > 0 {
+				err = errs
+			}
+		}
+		if err != nil {
+			im.logger.Printf("error while processing %s, %v", in, err)
+			statuses[i].Started = time.Now()
+			statuses[i].Finished = statuses[i].Started
+			statuses[i].SetError(err)
+			im.fileDone(statuses[i])
+			continue
+		}
+
+		table := DefaultTableName(in.Name)
+		if v.GetString("table") != "" {
+			table = v.GetString("table")
+		}
+
+		group, exists := tables[table]
+		if !exists {
+			group = &importGroup{table: table}
+			tables[table] = group
+			groups = append(groups, group)
+		}
+		group.files = append(group.files, &groupFile{in: in, v: v, status: &statuses[i]})
+	}
+
+	return groups
+}
+
+// importGroupFiles imports the files of a group, stopping at the first failure.
+// If store is not nil, the group is skipped when the content and configuration of all its files
+// didn't change since their last successful import.
+func (im *Importer) importGroupFiles(ctx context.Context, store *StateStore, group *importGroup) {
+	checksums := make([]string, len(group.files))
+
+	if store != nil {
+		unchanged := true
+		for i, file := range group.files {
+			var err error
+			checksums[i], err = getStateChecksum(file.in, file.v, file.status)
+			if err != nil {
+				im.failGroup(group, 0, err)
+				return
+			}
+
+			unchanged = unchanged && store.Unchanged(file.status.FileName, checksums[i])
+		}
+
+		if unchanged {
+			for _, file := range group.files {
+				im.logger.Printf("skipping %s, unchanged since last import", file.status.FileName)
+				file.status.Started = time.Now()
+				file.status.Finished = file.status.Started
+				file.status.Status = StatusSkipped
+				im.fileDone(*file.status)
+			}
+			return
+		}
+	}
+
+	for i, file := range group.files {
+		// only the first file may drop or truncate the table
+		if i > 0 {
+			file.v.Set("drop", false)
+			file.v.Set("truncate", false)
+		}
+
+		im.importFile(ctx, file, group)
+		if file.status.Status == StatusCancelled {
+			im.failGroup(group, i+1, file.status.Error)
+			return
+		}
+		if file.status.Status != StatusImported {
+			im.failGroup(group, i+1, fmt.Errorf("not imported, %s failed", file.status.FileName))
+			return
+		}
+
+		// remember the imported file
+		if store != nil {
+			err := store.Set(file.status.FileName, checksums[i])
+			if err != nil {
+				im.logger.Printf("unable to save state of %s, %v", file.status.FileName, err)
+			}
+		}
+	}
+}
+
+// failGroup sets the error of the files of a group not processed yet, starting with file index from
+func (im *Importer) failGroup(group *importGroup, from int, err error) {
+	for _, file := range group.files[from:] {
+		if file.status.Status != "" {
+			continue
+		}
+
+		im.logger.Printf("error while processing %s, %v", file.status.FileName, err)
+		file.status.Started = time.Now()
+		file.status.Finished = file.status.Started
+		file.status.SetError(err)
+		im.fileDone(*file.status)
+	}
+}
+
+// importFile imports a single file of a group and sets its status
+func (im *Importer) importFile(ctx context.Context, file *groupFile, group *importGroup) {
+	status := file.status
+	status.Started = time.Now()
+	defer func() { im.fileDone(*status) }()
+
+	// don't start new imports once cancelled
+	if err := ctx.Err(); err != nil {
+		status.Finished = status.Started
+		status.SetError(err)
+		return
+	}
+
+	var err error
+	if im.beforeFile != nil {
+		err = im.beforeFile(file.in, file.v)
+	}
+
+	service := im.newService()
+	if err == nil {
+		err = processInput(ctx, withContext(service), file.in, file.v, group, status)
+	}
+	if err != nil {
+		im.logger.Printf("error while processing %s, %v", status.FileName, err)
+	}
+	status.Finished = time.Now()
+	status.SetError(err)
+
+	im.audit(ctx, service, *status)
+}
+
+// fileDone calls the after file hook
+func (im *Importer) fileDone(status ImportFileStatus) {
+	if im.afterFile != nil {
+		im.afterFile(status)
+	}
+}
+
+// getStateChecksum calculates the checksum of a file and its configuration, used to detect unchanged files.
+// The checksum of the file content is set in status.
+func getStateChecksum(in Input, v *viper.Viper, status *ImportFileStatus) (string, error) {
+	fileChecksum, err := InputChecksum(in)
+	if err != nil {
+		return "", err
+	}
+	status.Checksum = fileChecksum
+
+	return ConfigChecksum(fileChecksum, v)
+}
+
+// processInput reads an input and imports it into the table of its group.
+// The row count, size and checksum of the (uncompressed) csv are set in status.
+func processInput(ctx context.Context, service DbServiceContext, in Input, v *viper.Viper,
+	group *importGroup, status *ImportFileStatus) error {
+	f, err := in.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return importReader(ctx, &groupService{service, group}, in.Name, f, v, status)
+}
+
+// groupService checks that all files of a group have the same header
+type groupService struct {
+	DbServiceContext
+
+	group *importGroup
+}
+
+// ProcessHeaderContext compares the header to the header of the previous files of the group
+func (s *groupService) ProcessHeaderContext(ctx context.Context, header []string) error {
+	cols := SanitizeNames(header)
+	if s.group.header == nil {
+		s.group.header = cols
+	} else if !equalNames(cols, s.group.header) {
+		return fmt.Errorf("header doesn't match the header of the previous files of table %s", s.group.table)
+	}
+
+	return s.DbServiceContext.ProcessHeaderContext(ctx, header)
+}
+
+// equalNames checks whether two lists of names are identical
+func equalNames(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,457 @@
+package csv2table
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Logger is the interface used by the Importer to log its progress, implemented by *log.Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Importer imports csv files with a DbService backend. It is the library counterpart of the csv2table
+// command, reporting errors instead of exiting.
+type Importer struct {
+	newService  func() DbService
+	settings    map[string]interface{} // global configuration, the file configurations are merged into it
+	configFiles []string               // global configuration files, in merge order
+	config      Config
+	logger      Logger
+
+	beforeFile  func(in Input, v *viper.Viper) error
+	afterFile   func(status ImportFileStatus)
+	afterImport func(statuses []ImportFileStatus)
+}
+
+// Option configures an Importer
+type Option func(im *Importer) error
+
+// WithBackend sets the function creating the DbService of each imported file, e.g. mysql.NewService.
+// It is required.
+func WithBackend(newService func() DbService) Option {
+	return func(im *Importer) error {
+		im.newService = newService
+		return nil
+	}
+}
+
+// WithConfigFile merges a global configuration file, e.g. csv2table.toml.
+// It can be used more than once, the last file wins.
+func WithConfigFile(fileName string) Option {
+	return func(im *Importer) error {
+		settings, err := readConfigFile(fileName)
+		if err != nil {
+			return fmt.Errorf("unable to read global config file, %v", err)
+		}
+
+		mergeSettings(im.settings, settings)
+		im.configFiles = append(im.configFiles, fileName)
+		return nil
+	}
+}
+
+// WithViper merges the settings of v into the global configuration
+func WithViper(v *viper.Viper) Option {
+	return func(im *Importer) error {
+		mergeSettings(im.settings, v.AllSettings())
+		return nil
+	}
+}
+
+// WithLogger sets the logger, the standard logger by default
+func WithLogger(logger Logger) Option {
+	return func(im *Importer) error {
+		im.logger = logger
+		return nil
+	}
+}
+
+// WithBeforeFile sets a hook called before a file is imported, with its merged configuration
+// which can still be changed. Returning an error fails the file. Like the after file hook,
+// it is called concurrently when more than one file is imported at the same time.
+func WithBeforeFile(hook func(in Input, v *viper.Viper) error) Option {
+	return func(im *Importer) error {
+		im.beforeFile = hook
+		return nil
+	}
+}
+
+// WithAfterFile sets a hook called after a file was processed, whatever its status.
+// It is called concurrently when more than one file is imported at the same time, see Config.ParallelFiles.
+func WithAfterFile(hook func(status ImportFileStatus)) Option {
+	return func(im *Importer) error {
+		im.afterFile = hook
+		return nil
+	}
+}
+
+// WithAfterImport sets a hook called after all files of an import were processed and archived
+func WithAfterImport(hook func(statuses []ImportFileStatus)) Option {
+	return func(im *Importer) error {
+		im.afterImport = hook
+		return nil
+	}
+}
+
+// NewImporter creates an Importer
+func NewImporter(options ...Option) (*Importer, error) {
+	im := &Importer{
+		settings: make(map[string]interface{}),
+		logger:   log.Default(),
+	}
+
+	for _, option := range options {
+		err := option(im)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if im.newService == nil {
+		return nil, errors.New("a backend is required to import csv files")
+	}
+
+	// the templates of the global configuration may be used by the file configurations
+	settings := make(map[string]interface{})
+	mergeSettings(settings, im.settings)
+	if err := applyTemplates(settings); err != nil {
+		return nil, err
+	}
+
+	var err error
+	im.config, err = UnmarshallConfig(im.globalViper())
+	if err != nil {
+		// locate the invalid options in the configuration files
+		if errs := im.Validate(); len(errs) > 0 {
+			return nil, errs
+		}
+		return nil, err
+	}
+
+	// in strict mode an invalid global configuration fails before any import
+	if im.config.Strict {
+		if errs := im.Validate(); len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	return im, nil
+}
+
+// Config returns the generic configuration read from the global configuration
+func (im *Importer) Config() Config {
+	return im.config
+}
+
+// ImportDir imports the csv files of a directory that have a matching configuration file,
+// see ScanDir. When ctx is cancelled, the file being imported keeps only its committed batches
+// and the remaining files are not imported, all of them being reported as cancelled.
+func (im *Importer) ImportDir(ctx context.Context, dir string) ([]ImportFileStatus, error) {
+	inputs, err := im.ScanDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return im.ImportInputs(ctx, inputs)
+}
+
+// ImportFile imports a single file, a configuration file is not required
+func (im *Importer) ImportFile(ctx context.Context, fileName string) ([]ImportFileStatus, error) {
+	inputs, err := FileInputs(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return im.ImportInputs(ctx, inputs)
+}
+
+// ImportReader imports the csv data read from r into table, using the global configuration.
+// If table is empty, the table of the global configuration is used.
+func (im *Importer) ImportReader(ctx context.Context, r io.Reader, table string) (ImportFileStatus, error) {
+	service := im.newService()
+
+	status, err := ImportReader(ctx, service, r, table, im.globalViper())
+	if status.FileName == "" {
+		// no table, nothing was imported
+		return status, err
+	}
+	if err != nil {
+		im.logger.Printf("error while processing %s, %v", status.FileName, err)
+	}
+	status.RunID = NewRunID()
+
+	im.audit(ctx, service, status)
+	if im.afterFile != nil {
+		im.afterFile(status)
+	}
+
+	statuses := []ImportFileStatus{status}
+	if im.afterImport != nil {
+		im.afterImport(statuses)
+	}
+
+	aerr := AfterImport(im.config, statuses)
+	if aerr != nil {
+		im.logger.Printf("error while running after import routine, %v", aerr)
+	}
+
+	return status, err
+}
+
+// ScanDir finds the inputs of a directory that have a matching configuration file (.toml),
+// applying the scan options of the configuration
+func (im *Importer) ScanDir(dir string) ([]Input, error) {
+	found, err := scanDir(dir, im.config, im.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []Input
+	for _, in := range found {
+		// in order for a file to be processed it must have a matching configuration file
+		if !in.HasConfigFile() {
+			continue
+		}
+
+		inputs = append(inputs, in)
+	}
+
+	if len(found) == 0 {
+		im.logger.Printf("no files found in %s", dir)
+	}
+
+	return inputs, nil
+}
+
+// ImportInputs imports a list of inputs, archives them and runs the after import routine.
+// It returns the import statuses, in the order of the inputs. The returned error is about the
+// import as a whole, e.g. the state file can't be read, the errors of the files are set in their status.
+func (im *Importer) ImportInputs(ctx context.Context, inputs []Input) ([]ImportFileStatus, error) {
+	// checksums of already imported files
+	var store *StateStore
+	if im.config.SkipUnchanged {
+		var err error
+		store, err = LoadStateStore(im.config.StateFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load state file %s, %v", im.config.StateFile, err)
+		}
+	}
+
+	// import status list collected from each processed file, in the order of the files
+	statuses := im.importFiles(ctx, store, NewRunID(), inputs)
+	if ctx.Err() != nil {
+		im.logger.Printf("import cancelled")
+	}
+
+	im.archiveFiles(statuses)
+
+	if im.afterImport != nil {
+		im.afterImport(statuses)
+	}
+
+	err := AfterImport(im.config, statuses)
+	if err != nil {
+		return statuses, fmt.Errorf("error while running after import routine, %v", err)
+	}
+
+	return statuses, nil
+}
+
+// importFiles imports a list of files, running at most Config.ParallelFiles imports at the same time.
+// Files sharing a target table are imported one after the other, as a group.
+func (im *Importer) importFiles(ctx context.Context, store *StateStore, runID string, inputs []Input) []ImportFileStatus {
+	parallelFiles := im.config.ParallelFiles
+	if parallelFiles < 1 {
+		parallelFiles = 1
+	}
+
+	statuses := make([]ImportFileStatus, len(inputs))
+	groups := im.groupFiles(inputs, runID, statuses)
+	slots := make(chan struct{}, parallelFiles)
+
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(group *importGroup) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			im.importGroupFiles(ctx, store, group)
+		}(group)
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+// archiveFiles moves the processed files to the archive directories and applies the archive retention.
+// A zip archive is moved once, to the error directory if any of its entries failed.
+func (im *Importer) archiveFiles(statuses []ImportFileStatus) {
+	now := time.Now()
+
+	var dirs []string
+	paths, files := FileStatuses(statuses)
+	for _, path := range paths {
+		dirs = append(dirs, filepath.Dir(path))
+
+		dest, err := ArchiveFile(im.config, files[path], now)
+		if err != nil {
+			im.logger.Printf("unable to archive %s, %v", path, err)
+			continue
+		}
+
+		if dest != "" {
+			im.logger.Printf("archived %s to %s", path, dest)
+		}
+	}
+
+	err := CleanArchive(im.config, now, dirs...)
+	if err != nil {
+		im.logger.Printf("unable to clean archive, %v", err)
+	}
+}
+
+// FileStatuses combines the statuses of the entries of a file, returning the file paths in order
+// and the status of each file. A failure or cancellation of an entry decides for the whole file.
+func FileStatuses(statuses []ImportFileStatus) ([]string, map[string]ImportFileStatus) {
+	var paths []string
+	files := make(map[string]ImportFileStatus)
+	for _, status := range statuses {
+		file, exists := files[status.Path]
+		if !exists {
+			paths = append(paths, status.Path)
+			file = status
+		}
+
+		if status.Status == StatusCancelled ||
+			(status.Status == StatusFailed && file.Status != StatusCancelled) {
+			file.Status = status.Status
+		}
+		files[status.Path] = file
+	}
+
+	return paths, files
+}
+
+// globalViper returns a new viper holding the global configuration
+func (im *Importer) globalViper() *viper.Viper {
+	// a copy, the file configurations are merged into it
+	settings := make(map[string]interface{})
+	mergeSettings(settings, im.settings)
+
+	// unknown templates are reported by NewImporter
+	applyTemplates(settings)
+
+	return newViper(settings)
+}
+
+// fileViper returns a new viper merging the global configuration with the configuration files of an input, if any
+func (im *Importer) fileViper(in Input) (*viper.Viper, error) {
+	settings := make(map[string]interface{})
+	mergeSettings(settings, im.settings)
+
+	for _, configFile := range im.inputConfigFiles(in) {
+		fileSettings, err := readConfigFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read config file (%s), %v", configFile, err)
+		}
+		mergeSettings(settings, fileSettings)
+	}
+
+	err := applyTemplates(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	v := newViper(settings)
+
+	// table shared by the files matching a pattern
+	if in.Table != "" {
+		v.Set("table", in.Table)
+	}
+
+	return v, nil
+}
+
+// inputConfigFiles returns the configuration files of an input, in merge order: the inherited directory
+// configuration files not read as global configuration files, then the configuration file of the input, if any
+func (im *Importer) inputConfigFiles(in Input) []string {
+	global := make(map[string]bool)
+	for _, configFile := range im.configFiles {
+		global[absPath(configFile)] = true
+	}
+
+	var files []string
+	for _, configFile := range in.DirConfigs {
+		if !global[absPath(configFile)] {
+			files = append(files, configFile)
+			global[absPath(configFile)] = true
+		}
+	}
+
+	// a configuration file set explicitly must exist, e.g. by a file pattern
+	if (in.Config != "" || in.HasConfigFile()) && !global[absPath(in.ConfigFileName())] {
+		files = append(files, in.ConfigFileName())
+	}
+
+	return files
+}
+
+// newViper returns a new viper holding settings
+func newViper(settings map[string]interface{}) *viper.Viper {
+	v := viper.New()
+	v.MergeConfigMap(settings)
+
+	// the CSV2TABLE_ environment variables override the configuration files
+	bindEnv(v)
+
+	return v
+}
+
+// absPath returns the absolute path of a file, or the path itself if it can't be determined
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	return abs
+}
+
+// audit records the import history of a file, if supported by the service, even if the import was cancelled
+func (im *Importer) audit(ctx context.Context, service DbService, status ImportFileStatus) {
+	if auditor, ok := service.(Auditor); ok {
+		err := auditor.Audit(context.WithoutCancel(ctx), status)
+		if err != nil {
+			im.logger.Printf("error while auditing %s, %v", status.FileName, err)
+		}
+	}
+}
+
+// mergeSettings deep merges the src settings into dest, copying the nested maps
+func mergeSettings(dest map[string]interface{}, src map[string]interface{}) {
+	for key, value := range src {
+		if m, ok := value.(map[string]interface{}); ok {
+			sub, ok := dest[key].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				dest[key] = sub
+			}
+			mergeSettings(sub, m)
+			continue
+		}
+
+		dest[key] = value
+	}
+}
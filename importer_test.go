@@ -0,0 +1,191 @@
+package csv2table
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// tableBackend records the rows imported into each table by the services it creates
+type tableBackend struct {
+	mu     sync.Mutex
+	tables map[string][][]string
+}
+
+func (b *tableBackend) newService() DbService {
+	return &tableService{backend: b}
+}
+
+// tableService is the DbService of a tableBackend
+type tableService struct {
+	backend *tableBackend
+	table   string
+}
+
+func (s *tableService) Start(fileName string, v *viper.Viper) error {
+	s.table = v.GetString("table")
+	if s.table == "" {
+		s.table = DefaultTableName(fileName)
+	}
+	return nil
+}
+
+func (s *tableService) End() error {
+	return nil
+}
+
+func (s *tableService) ProcessHeader(header []string) error {
+	return nil
+}
+
+func (s *tableService) ProcessLine(line []string) error {
+	s.backend.mu.Lock()
+	defer s.backend.mu.Unlock()
+
+	s.backend.tables[s.table] = append(s.backend.tables[s.table], line)
+	return nil
+}
+
+// writeFiles writes files into dir, by name
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		assert.Nil(t, err)
+	}
+}
+
+func TestImporterImportDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sales_1.csv": "id;amount\n1;10\n",
+		"sales_2.csv": "id;amount\n2;20\n",
+		"sales.toml":  "table = \"sales\"",
+		"stock.csv":   "id\n1\n",
+		"stock.toml":  "",
+		"other.csv":   "id\n1\n", // no configuration file
+	})
+
+	global := viper.New()
+	global.Set("files", []map[string]interface{}{{"pattern": "sales_*.csv", "config": "sales.toml"}})
+
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	var done []string
+	im, err := NewImporter(
+		WithBackend(backend.newService),
+		WithViper(global),
+		WithAfterFile(func(status ImportFileStatus) { done = append(done, status.FileName) }),
+	)
+	assert.Nil(t, err)
+
+	statuses, err := im.ImportDir(context.Background(), dir)
+	assert.Nil(t, err)
+	assert.Equal(t, len(statuses), 3)
+	for _, status := range statuses {
+		assert.Equal(t, status.Status, StatusImported)
+	}
+	assert.Equal(t, len(done), 3)
+
+	assert.Equal(t, backend.tables["sales"], [][]string{{"1", "10"}, {"2", "20"}})
+	assert.Equal(t, backend.tables["stock"], [][]string{{"1"}})
+}
+
+func TestImporterHeaderMismatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.csv":  "id;amount\n1;10\n",
+		"a.toml": "table = \"sales\"",
+		"b.csv":  "id;price\n2;20\n",
+		"b.toml": "table = \"sales\"",
+		"c.csv":  "id;amount\n3;30\n",
+		"c.toml": "table = \"sales\"",
+	})
+
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	im, err := NewImporter(WithBackend(backend.newService))
+	assert.Nil(t, err)
+
+	statuses, err := im.ImportDir(context.Background(), dir)
+	assert.Nil(t, err)
+	assert.Equal(t, statuses[0].Status, StatusImported)
+	assert.Equal(t, statuses[1].Status, StatusFailed)
+	assert.Equal(t, statuses[2].Status, StatusFailed)
+	assert.Equal(t, backend.tables["sales"], [][]string{{"1", "10"}})
+}
+
+func TestImporterImportFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Daily Sales.csv": "id\n1\n"})
+
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	im, err := NewImporter(WithBackend(backend.newService))
+	assert.Nil(t, err)
+
+	// no configuration file is required, the table is named after the file
+	statuses, err := im.ImportFile(context.Background(), filepath.Join(dir, "Daily Sales.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, statuses[0].Status, StatusImported)
+	assert.Equal(t, backend.tables["daily_sales"], [][]string{{"1"}})
+}
+
+func TestImporterImportReader(t *testing.T) {
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	im, err := NewImporter(WithBackend(backend.newService))
+	assert.Nil(t, err)
+
+	status, err := im.ImportReader(context.Background(), strings.NewReader("id\n1\n2\n"), "numbers")
+	assert.Nil(t, err)
+	assert.Equal(t, status.RowCount, 2)
+	assert.NotEqual(t, status.RunID, "")
+	assert.Equal(t, backend.tables["numbers"], [][]string{{"1"}, {"2"}})
+}
+
+func TestImporterBeforeFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.csv": "id\n1\n", "a.toml": ""})
+
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	im, err := NewImporter(
+		WithBackend(backend.newService),
+		WithBeforeFile(func(in Input, v *viper.Viper) error { return errors.New("rejected") }),
+	)
+	assert.Nil(t, err)
+
+	statuses, err := im.ImportDir(context.Background(), dir)
+	assert.Nil(t, err)
+	assert.Equal(t, statuses[0].Status, StatusFailed)
+	assert.Equal(t, len(backend.tables), 0)
+}
+
+func TestNewImporter(t *testing.T) {
+	// a backend is required
+	_, err := NewImporter()
+	assert.NotNil(t, err)
+
+	_, err = NewImporter(WithBackend(func() DbService { return nil }), WithConfigFile("missing.toml"))
+	assert.NotNil(t, err)
+
+	global := viper.New()
+	global.Set("parallelFiles", 4)
+	im, err := NewImporter(WithBackend(func() DbService { return nil }), WithViper(global))
+	assert.Nil(t, err)
+	assert.Equal(t, im.Config().ParallelFiles, 4)
+}
+
+func TestMergeSettings(t *testing.T) {
+	src := map[string]interface{}{"email": map[string]interface{}{"host": "a"}, "table": "t"}
+	dest := make(map[string]interface{})
+	mergeSettings(dest, src)
+	mergeSettings(dest, map[string]interface{}{"email": map[string]interface{}{"port": 25}})
+
+	assert.Equal(t, dest, map[string]interface{}{"email": map[string]interface{}{"host": "a", "port": 25}, "table": "t"})
+
+	// the nested maps are copied
+	assert.Equal(t, src["email"], map[string]interface{}{"host": "a"})
+}
@@ -0,0 +1,219 @@
+package csv2table
+
+import (
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// supported compression extensions of csv files
+const (
+	extGzip  = ".gz"
+	extBzip2 = ".bz2"
+	extZstd  = ".zst"
+	extZip   = ".zip"
+)
+
+// Input is a csv source to be imported: a plain or compressed csv file, or a csv entry of a zip archive
+type Input struct {
+	Name  string // csv name, used to find the configuration file and the default table name
+	Path  string // path of the file on disk
+	Entry string // name of the entry inside a zip archive, empty for other files
+
+	Config string // configuration file shared with other inputs, see FilePattern
+	Table  string // target table set by a FilePattern, empty for the configured or default table
+
+	DirConfigs []string // inherited directory configuration files, from the scanned directory down, see DirConfigFile
+}
+
+// String returns the name identifying the input in logs and statuses
+func (in Input) String() string {
+	if in.Entry != "" {
+		return in.Path + "/" + in.Entry
+	}
+
+	return in.Path
+}
+
+// ConfigFileName returns the matching configuration file of the input. Unless set by a FilePattern,
+// it is found in the directory of the input file and named by its base name, e.g. data.toml for data.csv.gz
+func (in Input) ConfigFileName() string {
+	if in.Config != "" {
+		return in.Config
+	}
+
+	return filepath.Join(filepath.Dir(in.Path), BaseName(in.Name)+".toml")
+}
+
+// HasConfigFile checks whether the input has a matching configuration file
+func (in Input) HasConfigFile() bool {
+	_, err := os.Stat(in.ConfigFileName())
+	return err == nil
+}
+
+// Open opens the input, decompressing it on the fly
+func (in Input) Open() (io.ReadCloser, error) {
+	if in.Entry != "" {
+		return openZipEntry(in.Path, in.Entry)
+	}
+
+	f, err := os.Open(in.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	var closer io.Closer = f
+	switch strings.ToLower(filepath.Ext(in.Path)) {
+	case extGzip:
+		r, err = gzip.NewReader(f)
+	case extBzip2:
+		r = bzip2.NewReader(f)
+	case extZstd:
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(f)
+		if err == nil {
+			// the decoder must be closed too, to release its goroutines
+			rc := d.IOReadCloser()
+			r, closer = rc, multiCloser{rc, f}
+		}
+	default:
+		return f, nil
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return readCloser{r, closer}, nil
+}
+
+// IsInputFile checks whether a file name is a data file of a supported format (csv, xlsx...), possibly compressed,
+// or a zip archive
+func IsInputFile(name string) bool {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, extZip) {
+		return true
+	}
+
+	return dataExtension(trimCompression(name)) != ""
+}
+
+// FileInputs returns the inputs of a file: the file itself,
+// or the data file entries of a zip archive
+func FileInputs(path string) ([]Input, error) {
+	if !strings.HasSuffix(strings.ToLower(path), extZip) {
+		return []Input{{Name: filepath.Base(path), Path: path}}, nil
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var inputs []Input
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || dataExtension(f.Name) == "" {
+			continue
+		}
+
+		inputs = append(inputs, Input{
+			Name:  filepath.Base(f.Name),
+			Path:  path,
+			Entry: f.Name,
+		})
+	}
+
+	return inputs, nil
+}
+
+// BaseName returns the name of a data file without the format and compression extensions,
+// e.g. data for data.csv.gz or data.xlsx
+func BaseName(name string) string {
+	name = trimCompression(name)
+	return name[:len(name)-len(dataExtension(name))]
+}
+
+// DefaultTableName returns the table name used when a csv file has no configured table,
+// its sanitized base name
+func DefaultTableName(name string) string {
+	return SanitizeName(BaseName(name))
+}
+
+// dataExtension returns the extension of a data file of a supported format, empty for other files
+func dataExtension(name string) string {
+	ext := filepath.Ext(name)
+	if _, exists := formats[strings.ToLower(ext)]; exists {
+		return ext
+	}
+
+	return ""
+}
+
+// isCompressed checks whether a file is compressed or a zip archive
+func isCompressed(name string) bool {
+	return trimCompression(name) != name || strings.HasSuffix(strings.ToLower(name), extZip)
+}
+
+// trimCompression removes the compression extension from a file name
+func trimCompression(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == extGzip || ext == extBzip2 || ext == extZstd {
+		return name[:len(name)-len(ext)]
+	}
+
+	return name
+}
+
+// openZipEntry opens an entry of a zip archive, closing the archive together with the entry
+func openZipEntry(path string, entry string) (io.ReadCloser, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range r.File {
+		if f.Name != entry {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+
+		return readCloser{rc, multiCloser{rc, r}}, nil
+	}
+
+	r.Close()
+	return nil, fmt.Errorf("entry %s not found in %s", entry, path)
+}
+
+// readCloser reads from a decompressing reader and closes the underlying file
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// multiCloser closes several io.Closer, returning the first error
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var err error
+	for _, c := range m {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
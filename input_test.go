@@ -0,0 +1,76 @@
+package csv2table
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseName(t *testing.T) {
+	assert.Equal(t, BaseName("data.csv"), "data")
+	assert.Equal(t, BaseName("data.CSV.gz"), "data")
+	assert.Equal(t, BaseName("data.csv.zst"), "data")
+	assert.True(t, IsInputFile("data.csv.bz2"))
+	assert.True(t, IsInputFile("batch.zip"))
+	assert.False(t, IsInputFile("data.toml"))
+}
+
+func TestInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csv2table")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// gzip file
+	gzPath := filepath.Join(dir, "data.csv.gz")
+	f, _ := os.Create(gzPath)
+	w := gzip.NewWriter(f)
+	w.Write([]byte("a;b\n1;2\n"))
+	w.Close()
+	f.Close()
+
+	inputs, err := FileInputs(gzPath)
+	if assert.Nil(t, err) && assert.Len(t, inputs, 1) {
+		assert.Equal(t, inputs[0].ConfigFileName(), filepath.Join(dir, "data.toml"))
+		assertContent(t, inputs[0], "a;b\n1;2\n")
+	}
+
+	// zip archive
+	zipPath := filepath.Join(dir, "batch.zip")
+	f, _ = os.Create(zipPath)
+	zw := zip.NewWriter(f)
+	e, _ := zw.Create("orders.csv")
+	e.Write([]byte("id\n1\n"))
+	e, _ = zw.Create("readme.txt")
+	e.Write([]byte("not a csv"))
+	e, _ = zw.Create("sub/customers.csv")
+	e.Write([]byte("id\n2\n"))
+	zw.Close()
+	f.Close()
+
+	inputs, err = FileInputs(zipPath)
+	if assert.Nil(t, err) && assert.Len(t, inputs, 2) {
+		assert.Equal(t, inputs[1].Name, "customers.csv")
+		assert.Equal(t, inputs[1].ConfigFileName(), filepath.Join(dir, "customers.toml"))
+		assertContent(t, inputs[0], "id\n1\n")
+		assertContent(t, inputs[1], "id\n2\n")
+	}
+}
+
+func assertContent(t *testing.T, in Input, expected string) {
+	r, err := in.Open()
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, string(content), expected)
+}
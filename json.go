@@ -0,0 +1,334 @@
+package csv2table
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// array options of the json files
+const (
+	ArraysString  = "string"
+	ArraysExplode = "explode"
+)
+
+// defaultScanRecords is the number of records scanned for columns when they are not declared
+const defaultScanRecords = 100
+
+// jsonField is a field of a json object, objects keep the order of their fields
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+// jsonObject is a json object decoded in order
+type jsonObject []jsonField
+
+// MarshalJSON encodes the object keeping the order of its fields
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// jsonRow is a flattened record, by column name
+type jsonRow map[string]string
+
+// jsonReader reads json objects, either the elements of an array or a stream of objects (JSON Lines).
+// Nested objects are flattened, their keys joined with "_".
+type jsonReader struct {
+	dec     *json.Decoder
+	array   bool // the records are the elements of an array
+	explode bool // one row per array element, instead of the array as a json string
+	columns []string
+	header  bool      // header returned
+	rows    []jsonRow // rows read but not returned yet
+}
+
+// newJsonReader creates a json reader. The columns option declares the columns, otherwise they are
+// collected from the first scanRecords records.
+func newJsonReader(r io.Reader, v *viper.Viper) (*jsonReader, error) {
+	arrays := strings.ToLower(v.GetString("arrays"))
+	switch arrays {
+	case "", ArraysString, ArraysExplode:
+	default:
+		return nil, fmt.Errorf("invalid arrays option %s, expecting %s or %s", arrays, ArraysString, ArraysExplode)
+	}
+
+	br := bufio.NewReader(r)
+	array, err := isJsonArray(br)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &jsonReader{
+		dec:     json.NewDecoder(br),
+		array:   array,
+		explode: arrays == ArraysExplode,
+	}
+	j.dec.UseNumber()
+
+	if j.array {
+		// consume the opening bracket
+		if _, err := j.dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	j.columns = SanitizeNames(v.GetStringSlice("columns"))
+	if len(j.columns) > 0 {
+		return j, nil
+	}
+
+	scanRecords := defaultScanRecords
+	if v.IsSet("scanRecords") {
+		scanRecords = v.GetInt("scanRecords")
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < scanRecords; i++ {
+		rows, columns, err := j.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, column := range columns {
+			if !seen[column] {
+				seen[column] = true
+				j.columns = append(j.columns, column)
+			}
+		}
+		j.rows = append(j.rows, rows...)
+	}
+	if len(j.columns) == 0 {
+		return nil, fmt.Errorf("no columns found in the first %d records", scanRecords)
+	}
+
+	return j, nil
+}
+
+// isJsonArray reports whether the data is a json array, skipping the leading spaces and byte order mark
+func isJsonArray(br *bufio.Reader) (bool, error) {
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n', '\uFEFF':
+			continue
+		}
+
+		return c == '[', br.UnreadRune()
+	}
+}
+
+// Read returns the columns first, then the rows of the records. Fields that are not
+// among the columns are ignored.
+func (j *jsonReader) Read() ([]string, error) {
+	if !j.header {
+		j.header = true
+		return j.columns, nil
+	}
+
+	for len(j.rows) == 0 {
+		rows, _, err := j.next()
+		if err != nil {
+			return nil, err
+		}
+		j.rows = rows
+	}
+
+	row := j.rows[0]
+	j.rows = j.rows[1:]
+
+	record := make([]string, len(j.columns))
+	for i, column := range j.columns {
+		record[i] = row[column]
+	}
+
+	return record, nil
+}
+
+// next decodes the next record into its rows, along with its columns in order
+func (j *jsonReader) next() ([]jsonRow, []string, error) {
+	if !j.dec.More() {
+		// the closing bracket of the array, or the end of the stream
+		_, err := j.dec.Token()
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, nil, err
+	}
+
+	value, err := decodeJsonValue(j.dec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid json record, %v", err)
+	}
+	if _, ok := value.(jsonObject); !ok {
+		return nil, nil, fmt.Errorf("invalid json record, expecting an object, got %T", value)
+	}
+
+	var columns []string
+	rows, err := j.flatten("", value, []jsonRow{{}}, &columns)
+
+	return rows, columns, err
+}
+
+// flatten adds a value to the rows. Objects add a column per field, arrays are either encoded or
+// exploded into one row per element.
+func (j *jsonReader) flatten(name string, value interface{}, rows []jsonRow, columns *[]string) ([]jsonRow, error) {
+	switch value := value.(type) {
+	case jsonObject:
+		var err error
+		for _, field := range value {
+			key := field.key
+			if name != "" {
+				key = name + "_" + key
+			}
+			rows, err = j.flatten(key, field.value, rows, columns)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rows, nil
+
+	case []interface{}:
+		if !j.explode {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			return setColumn(rows, SanitizeName(name), string(encoded), columns), nil
+		}
+		if len(value) == 0 {
+			return setColumn(rows, SanitizeName(name), "", columns), nil
+		}
+
+		var exploded []jsonRow
+		for _, element := range value {
+			elementRows := make([]jsonRow, len(rows))
+			for i, row := range rows {
+				elementRows[i] = row.copy()
+			}
+
+			elementRows, err := j.flatten(name, element, elementRows, columns)
+			if err != nil {
+				return nil, err
+			}
+			exploded = append(exploded, elementRows...)
+		}
+		return exploded, nil
+	}
+
+	return setColumn(rows, SanitizeName(name), jsonScalar(value), columns), nil
+}
+
+// setColumn sets the value of a column in all rows
+func setColumn(rows []jsonRow, column string, value string, columns *[]string) []jsonRow {
+	*columns = append(*columns, column)
+	for _, row := range rows {
+		row[column] = value
+	}
+
+	return rows
+}
+
+// copy returns a copy of the row
+func (r jsonRow) copy() jsonRow {
+	c := make(jsonRow, len(r))
+	for column, value := range r {
+		c[column] = value
+	}
+
+	return c
+}
+
+// jsonScalar converts a json scalar to a column value. Null is empty, booleans are 1 or 0.
+func jsonScalar(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if value {
+			return "1"
+		}
+		return "0"
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	}
+
+	return fmt.Sprint(value)
+}
+
+// decodeJsonValue decodes the next value, keeping the order of the object fields
+func decodeJsonValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := jsonObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJsonValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, jsonField{key: key.(string), value: value})
+		}
+		_, err = dec.Token()
+		return object, err
+
+	case json.Delim('['):
+		array := []interface{}{}
+		for dec.More() {
+			value, err := decodeJsonValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = dec.Token()
+		return array, err
+	}
+
+	return token, nil
+}
@@ -0,0 +1,93 @@
+package csv2table
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonReaderLines(t *testing.T) {
+	data := `{"id": 1, "Customer Info": {"Name": "Ana", "zip-code": "0123"}, "tags": ["a", {"b": 2}], "active": true}
+{"id": 2.50, "customer info": {"name": null}, "extra": "x"}
+`
+
+	r, err := newJsonReader(strings.NewReader(data), viper.New())
+	assert.Nil(t, err)
+	assert.Equal(t, readAll(t, r), [][]string{
+		{"id", "customer_info_name", "customer_info_zip_code", "tags", "active", "extra"},
+		{"1", "Ana", "0123", `["a",{"b":2}]`, "1", ""},
+		{"2.50", "", "", "", "", "x"},
+	})
+}
+
+func TestJsonReaderArray(t *testing.T) {
+	v := viper.New()
+	v.Set("scanRecords", 1)
+
+	// fields of the records after the scanned ones are ignored
+	data := "\ufeff [{\"id\": 1}, {\"id\": 2, \"name\": \"b\"}]"
+
+	r, err := newJsonReader(strings.NewReader(data), v)
+	assert.Nil(t, err)
+	assert.Equal(t, readAll(t, r), [][]string{{"id"}, {"1"}, {"2"}})
+}
+
+func TestJsonReaderColumns(t *testing.T) {
+	v := viper.New()
+	v.Set("columns", []string{"name", "Address City"})
+
+	data := `{"id": 1, "name": "a", "address": {"city": "Cluj"}}`
+
+	r, err := newJsonReader(strings.NewReader(data), v)
+	assert.Nil(t, err)
+	assert.Equal(t, readAll(t, r), [][]string{{"name", "address_city"}, {"a", "Cluj"}})
+}
+
+func TestJsonReaderExplode(t *testing.T) {
+	v := viper.New()
+	v.Set("arrays", ArraysExplode)
+
+	data := `{"order": 1, "lines": [{"sku": "x", "qty": 2}, {"sku": "y"}], "notes": []}
+{"order": 2, "lines": ["z"]}`
+
+	r, err := newJsonReader(strings.NewReader(data), v)
+	assert.Nil(t, err)
+	assert.Equal(t, readAll(t, r), [][]string{
+		{"order", "lines_sku", "lines_qty", "notes", "lines"},
+		{"1", "x", "2", "", ""},
+		{"1", "y", "", "", ""},
+		{"2", "", "", "", "z"},
+	})
+}
+
+func TestJsonReaderInvalid(t *testing.T) {
+	v := viper.New()
+	v.Set("arrays", "split")
+	_, err := newJsonReader(strings.NewReader(`{"id": 1}`), v)
+	assert.NotNil(t, err)
+
+	_, err = newJsonReader(strings.NewReader(`[1, 2]`), viper.New())
+	assert.NotNil(t, err)
+
+	_, err = newJsonReader(strings.NewReader(""), viper.New())
+	assert.NotNil(t, err)
+
+	v = viper.New()
+	v.Set("columns", []string{"id"})
+	r, err := newJsonReader(strings.NewReader(`{"id": 1} {"id": `), v)
+	assert.Nil(t, err)
+	_, err = r.Read()
+	assert.Nil(t, err)
+	_, err = r.Read()
+	assert.Nil(t, err)
+	_, err = r.Read()
+	assert.NotNil(t, err)
+}
+
+func TestJsonInputFile(t *testing.T) {
+	assert.True(t, IsInputFile("events.ndjson.gz"))
+	assert.Equal(t, BaseName("events.jsonl"), "events")
+	assert.Equal(t, inputFormat("events.json", viper.New()), FormatJson)
+}
@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/schiorean/csv2table"
+)
+
+// import modes recorded in the audit table
+const (
+	modeDrop     = "drop"
+	modeTruncate = "truncate"
+	modeAppend   = "append"
+)
+
+// auditTableTpl is the definition of the audit table, {table} is replaced by the quoted table name
+const auditTableTpl = `create table if not exists {table} (
+	id INT(11) NOT NULL AUTO_INCREMENT,
+	run_id VARCHAR(64) NOT NULL,
+	file_name VARCHAR(1024) NOT NULL,
+	file_size BIGINT NULL DEFAULT NULL,
+	checksum CHAR(64) NULL DEFAULT NULL,
+	target_table VARCHAR(64) NOT NULL,
+	mode VARCHAR(16) NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	rows_read INT(11) NOT NULL,
+	rows_inserted INT(11) NOT NULL,
+	rows_rejected INT(11) NOT NULL,
+	started_at DATETIME(3) NOT NULL,
+	finished_at DATETIME(3) NOT NULL,
+	error TEXT NULL DEFAULT NULL,
+	PRIMARY KEY(id),
+	INDEX target_table (target_table, started_at),
+	INDEX run_id (run_id)
+)
+`
+
+// Audit implements csv2table.Auditor, it writes the import status of the file to the audit table.
+// Nothing is written if the auditTable option is not set.
+func (s *DbService) Audit(ctx context.Context, status csv2table.ImportFileStatus) error {
+	if s.config.AuditTable == "" {
+		return nil
+	}
+
+	// the connection is released by End
+	if s.db == nil {
+		err := s.connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer s.release()
+	}
+
+	table := quoteName(s.config.AuditTable)
+	err := s.exec(ctx, strings.Replace(auditTableTpl, "{table}", table, -1)+s.config.TableOptions)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`insert into %v (run_id, file_name, file_size, checksum, target_table, mode,
+		status, rows_read, rows_inserted, rows_rejected, started_at, finished_at, error)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, table), s.auditValues(status)...)
+	if err != nil {
+		return err
+	}
+
+	if s.config.Verbose {
+		log.Printf("Audited import of %v into %v\n", status.FileName, s.config.AuditTable)
+	}
+
+	return nil
+}
+
+// auditValues returns the values of an audit table row, in the order of the insert columns
+func (s *DbService) auditValues(status csv2table.ImportFileStatus) []interface{} {
+	var checksum, errorText interface{}
+	if status.Checksum != "" {
+		checksum = status.Checksum
+	}
+	if status.Error != nil {
+		errorText = status.Error.Error()
+	}
+
+	return []interface{}{status.RunID, status.FileName, status.Size, checksum, s.config.Table, s.mode(),
+		status.Status, status.RowCount, s.rowCount, s.rejected, status.Started, status.Finished, errorText}
+}
+
+// mode describes how the target table is loaded
+func (s *DbService) mode() string {
+	if s.config.Drop {
+		return modeDrop
+	}
+	if s.config.Truncate {
+		return modeTruncate
+	}
+
+	return modeAppend
+}
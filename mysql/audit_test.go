@@ -0,0 +1,46 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/schiorean/csv2table"
+)
+
+func TestMode(t *testing.T) {
+	s := NewService()
+	assert.Equal(t, s.mode(), modeAppend)
+
+	s.config.Truncate = true
+	assert.Equal(t, s.mode(), modeTruncate)
+
+	// drop wins over truncate
+	s.config.Drop = true
+	assert.Equal(t, s.mode(), modeDrop)
+}
+
+func TestAuditValues(t *testing.T) {
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	finished := started.Add(time.Minute)
+
+	s := NewService()
+	s.config.Table = "contracts"
+	s.config.Truncate = true
+	s.rowCount = 1000
+
+	status := csv2table.ImportFileStatus{FileName: "contracts.csv", Status: csv2table.StatusImported, RowCount: 1000,
+		RunID: "run1", Size: 2048, Checksum: "abc", Started: started, Finished: finished}
+	assert.Equal(t, s.auditValues(status), []interface{}{"run1", "contracts.csv", int64(2048), "abc", "contracts",
+		modeTruncate, csv2table.StatusImported, 1000, 1000, 0, started, finished, nil})
+
+	// the rows of the failed batches are rejected
+	s.rowCount = 400
+	s.rejected = 600
+	status.SetError(errors.New("connection reset"))
+	status.Checksum = ""
+	assert.Equal(t, s.auditValues(status), []interface{}{"run1", "contracts.csv", int64(2048), nil, "contracts",
+		modeTruncate, csv2table.StatusFailed, 1000, 400, 600, started, finished, "connection reset"})
+}
@@ -4,34 +4,18 @@ package mysql
 
 import "strings"
 
-var sqlEscapeReplacer *strings.Replacer
-
-// escapeString escapes a string by adding backslashes before special
-// characters, and turning others into specific escape sequences, such as
-// turning newlines into \n and null bytes into \0.
-// source https://github.com/go-sql-driver/mysql/blob/master/utils.go, escapeStringBackslash function
-func escapeString(us string) string {
-	if sqlEscapeReplacer == nil {
-		sqlEscapeReplacer = strings.NewReplacer(
-			"\x00", "\\0",
-			"\n", "\\n",
-			"\r", "\\r",
-			"\x1a", "\\Z",
-			"'", "\\'",
-			"\"", "\\\"",
-			"\\", "\\\\",
-		)
-	}
-
-	return sqlEscapeReplacer.Replace(us)
+// quoteName quotes a mysql identifier (table or column name) using backticks.
+// Backticks that are part of the name are doubled, as required by mysql.
+func quoteName(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
 }
 
-// escapeStrings applies escapeString over a list of strings
-func escapeStrings(us []string) []string {
-	es := make([]string, len(us))
-	for i, v := range us {
-		es[i] = escapeString(v)
+// quoteNames applies quoteName over a list of identifiers
+func quoteNames(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, v := range names {
+		quoted[i] = quoteName(v)
 	}
 
-	return es
+	return quoted
 }
@@ -6,7 +6,8 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestEscapeString(t *testing.T) {
-	assert.Equal(t, escapeString("Hi I'm \\ Sorin"), "Hi I\\'m \\\\ Sorin")
-	assert.Equal(t, escapeString("And \"author\" is me\r\n"), "And \\\"author\\\" is me\\r\\n")
+func TestQuoteName(t *testing.T) {
+	assert.Equal(t, quoteName("date_of_receipt"), "`date_of_receipt`")
+	assert.Equal(t, quoteName("my`table"), "`my``table`")
+	assert.Equal(t, quoteNames([]string{"a", "b"}), []string{"`a`", "`b`"})
 }
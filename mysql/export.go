@@ -0,0 +1,157 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+
+	"github.com/schiorean/csv2table"
+)
+
+// exportColumn is a column of an export
+type exportColumn struct {
+	name       string
+	columnType string // kind of the column type as understood by us
+	format     string // format of the column mapping
+}
+
+// exportRows implements csv2table.RowReader for the rows of a query
+type exportRows struct {
+	db      *sqlx.DB // connection owned by the rows, nil if shared by a pool
+	rows    *sql.Rows
+	columns []exportColumn
+}
+
+// Export starts reading the rows of the configured table, or of query if it is not empty.
+// The values are formatted by the format of their column mapping, the reverse of the import:
+// dates are formatted by their layout and the decimal point of floats and decimals is replaced.
+func (s *DbService) Export(ctx context.Context, v *viper.Viper, query string) (csv2table.RowReader, error) {
+	s.config = newConfig()
+	err := s.readConfig(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if query == "" {
+		if s.config.Table == "" {
+			return nil, errors.New("a table or a query is required to export")
+		}
+		query = "select * from " + quoteName(s.config.Table)
+	}
+
+	err = s.connect(ctx)
+	if err == nil {
+		var r *exportRows
+		r, err = s.query(ctx, query)
+		if err == nil {
+			// the rows own the connection, unless it is shared by a pool
+			if s.pool == nil {
+				r.db = s.db
+			}
+			s.db = nil
+			return r, nil
+		}
+	}
+
+	s.release()
+	return nil, err
+}
+
+// query runs the export query and reads its columns
+func (s *DbService) query(ctx context.Context, query string) (*exportRows, error) {
+	if s.config.Verbose {
+		log.Printf("Exporting %v\n", query)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	r := &exportRows{rows: rows}
+	for _, colType := range colTypes {
+		mapping := s.config.Mapping[csv2table.SanitizeName(colType.Name())]
+		r.columns = append(r.columns, exportColumn{
+			name:       colType.Name(),
+			columnType: exportType(colType.DatabaseTypeName()),
+			format:     mapping.Format,
+		})
+	}
+
+	return r, nil
+}
+
+// exportType returns the kind of a result column type, decimals being formatted like floats
+func exportType(dbType string) string {
+	if strings.EqualFold(dbType, "decimal") {
+		return typeFloat
+	}
+
+	return columnType(dbType)
+}
+
+// Columns returns the column names of the query
+func (r *exportRows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		names[i] = col.name
+	}
+
+	return names
+}
+
+// Read returns the next row, formatted by the column mapping
+func (r *exportRows) Read() ([]*string, error) {
+	if !r.rows.Next() {
+		err := r.rows.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+
+	values := make([]sql.NullString, len(r.columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	err := r.rows.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	row := make([]*string, len(values))
+	for i, value := range values {
+		if value.Valid {
+			formatted := exportValue(r.columns[i].columnType, r.columns[i].format, value.String)
+			row[i] = &formatted
+		}
+	}
+
+	return row, nil
+}
+
+// Close closes the rows and the database connection
+func (r *exportRows) Close() error {
+	err := r.rows.Close()
+	if r.db != nil {
+		if cerr := r.db.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
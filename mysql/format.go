@@ -10,8 +10,9 @@ const (
 	defaultFloatFormat = "1.2" // EN-US, the decimal point is the dot "."
 )
 
-// global list of prepared float parsers found in configuration files
-var floatParsers map[string]*strings.Replacer
+// floatParsers holds the prepared float parsers found in configuration files, by format.
+// Each DbService has its own list, so it is never shared between goroutines.
+type floatParsers map[string]*strings.Replacer
 
 // formatColumn formats a column value based on various mapping flags
 func (s *DbService) formatColumn(col string, value string) (*string, error) {
@@ -20,6 +21,11 @@ func (s *DbService) formatColumn(col string, value string) (*string, error) {
 	mysqlValue := new(string)
 	*mysqlValue = value
 
+	// typed formats have no empty numbers or dates, only nulls
+	if value == "" && s.isTypedColumn(col) {
+		return nil, nil
+	}
+
 	mapping, exists := s.config.Mapping[col]
 	if !exists {
 		return mysqlValue, nil
@@ -39,7 +45,7 @@ func (s *DbService) formatColumn(col string, value string) (*string, error) {
 
 	// format: apply value formatting
 	if mysqlValue != nil {
-		*mysqlValue, err = parseType(s.config.ColumnType[col], mapping.Format, *mysqlValue)
+		*mysqlValue, err = s.parseType(s.config.ColumnType[col], mapping.Format, *mysqlValue)
 		if err != nil {
 			return nil, err
 		}
@@ -91,38 +97,33 @@ func parseDateTime(format string, value string) (string, error) {
 }
 
 // parseType parses a column value based on the column type and provided format
-func parseType(columnType string, format string, value string) (string, error) {
+func (s *DbService) parseType(columnType string, format string, value string) (string, error) {
 	switch columnType {
 	case typeDate:
 		return parseDate(format, value)
 	case typeDateTime:
 		return parseDateTime(format, value)
 	case typeFloat:
-		return parseFloat(format, value), nil
+		return s.floatParsers.parse(format, value), nil
 	}
 
 	return value, nil
 }
 
-// parseFloat parses a float column from an unknown locale to system locale.
+// parse parses a float column from an unknown locale to system locale.
 // The algorithtm is simple: the last non-numeric character in format string is considered the decimal point
-func parseFloat(format string, value string) string {
+func (p floatParsers) parse(format string, value string) string {
 	if format == "" {
 		format = defaultFloatFormat
 	}
 
-	parser, exists := floatParsers[format]
+	parser, exists := p[format]
 	if !exists {
-		// find kast non-numeric character => decimal point
-		re := regexp.MustCompile("[^0-9]")
-		match := re.FindAllString(format, -1)
-
-		if len(match) == 0 {
+		dp := decimalPoint(format)
+		if dp == "" {
 			return value
 		}
 
-		// decimal point is the last element
-		dp := match[len(match)-1]
 		if dp == "," {
 			parser = strings.NewReplacer(
 				".", "",
@@ -134,11 +135,46 @@ func parseFloat(format string, value string) string {
 			)
 		}
 
-		if floatParsers == nil {
-			floatParsers = make(map[string]*strings.Replacer)
-		}
-		floatParsers[format] = parser
+		p[format] = parser
 	}
 
 	return parser.Replace(value)
 }
+
+// decimalPoint returns the decimal point of a float format: the last non-numeric character
+func decimalPoint(format string) string {
+	re := regexp.MustCompile("[^0-9]")
+	match := re.FindAllString(format, -1)
+
+	if len(match) == 0 {
+		return ""
+	}
+
+	// decimal point is the last element
+	return match[len(match)-1]
+}
+
+// exportValue formats a db value by the format of its column mapping, the reverse of parseType.
+// Dates that can't be parsed, e.g. zero dates, are exported unchanged.
+func exportValue(columnType string, format string, value string) string {
+	if format == "" {
+		return value
+	}
+
+	switch columnType {
+	case typeDate:
+		if t, err := time.Parse("2006-01-02", value); err == nil {
+			return t.Format(format)
+		}
+	case typeDateTime:
+		if t, err := time.Parse("2006-01-02 15:04:05", value); err == nil {
+			return t.Format(format)
+		}
+	case typeFloat:
+		if decimalPoint(format) == "," {
+			return strings.Replace(value, ".", ",", 1)
+		}
+	}
+
+	return value
+}
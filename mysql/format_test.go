@@ -1,7 +1,12 @@
 package mysql
 
-import "testing"
-import "github.com/stretchr/testify/assert"
+import (
+	"testing"
+
+	"github.com/schiorean/csv2table"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
 
 func TestParseDate(t *testing.T) {
 	// EN
@@ -45,10 +50,57 @@ func TestApplyNull(t *testing.T) {
 }
 
 func TestParseFloat(t *testing.T) {
-	assert.Equal(t, parseFloat("1.2", "1,500.50"), "1500.50")
-	assert.Equal(t, parseFloat("1,2", "1500,50"), "1500.50")
+	p := floatParsers{}
+	assert.Equal(t, p.parse("1.2", "1,500.50"), "1500.50")
+	assert.Equal(t, p.parse("1,2", "1500,50"), "1500.50")
 
 	// default format is EN-US
-	assert.Equal(t, parseFloat("", "1500,50"), "150050")
-	assert.Equal(t, parseFloat("", "1500.50"), "1500.50")
+	assert.Equal(t, p.parse("", "1500,50"), "150050")
+	assert.Equal(t, p.parse("", "1500.50"), "1500.50")
+}
+
+func TestDataColType(t *testing.T) {
+	v := viper.New()
+	v.Set("dataTypes", map[string]string{
+		"id":     csv2table.DataTypeBigInt,
+		"name":   csv2table.DataTypeString,
+		"amount": csv2table.DecimalDataType(10, 2),
+		"huge":   csv2table.DecimalDataType(76, 0),
+	})
+	v.Set("mapping.id.type", "INT UNSIGNED NOT NULL")
+
+	s := NewService()
+	s.config = newConfig()
+	assert.Nil(t, v.Unmarshal(&s.config))
+
+	// the column mapping overrides the data type
+	assert.Equal(t, s.getColMapping("id").Type, "INT UNSIGNED NOT NULL")
+	assert.Equal(t, s.getColMapping("name").Type, defaultColType)
+	assert.Equal(t, s.getColMapping("amount").Type, "DECIMAL(10,2) NULL DEFAULT NULL")
+	assert.Equal(t, s.getColMapping("huge").Type, defaultColType)
+	assert.Equal(t, s.getColMapping("other").Type, defaultColType)
+
+	// empty values of typed columns are nulls
+	value, err := s.formatColumn("amount", "")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+
+	value, err = s.formatColumn("name", "")
+	assert.Nil(t, err)
+	assert.Equal(t, *value, "")
+}
+
+func TestExportValue(t *testing.T) {
+	assert.Equal(t, exportValue(typeDate, "02.01.2006", "2019-05-21"), "21.05.2019")
+	assert.Equal(t, exportValue(typeDateTime, "02.01.2006 15:04", "2019-05-21 01:22:59.5"), "21.05.2019 01:22")
+	assert.Equal(t, exportValue(typeFloat, "1.234,5", "-1500.50"), "-1500,50")
+	assert.Equal(t, exportValue(typeFloat, "1,234.5", "1500.50"), "1500.50")
+
+	// no format, or values that can't be parsed
+	assert.Equal(t, exportValue(typeDate, "", "2019-05-21"), "2019-05-21")
+	assert.Equal(t, exportValue(typeDate, "02.01.2006", "0000-00-00"), "0000-00-00")
+
+	assert.Equal(t, exportType("DECIMAL"), typeFloat)
+	assert.Equal(t, exportType("DATETIME"), typeDateTime)
+	assert.Equal(t, exportType("VARCHAR"), typeString)
 }
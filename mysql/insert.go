@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxPlaceholders is the maximum number of placeholders mysql accepts in a prepared statement
+	maxPlaceholders = 65535
+
+	// valueOverhead is the estimated number of bytes a value needs in a packet besides its content
+	// (type, length prefix and null bitmap)
+	valueOverhead = 12
+)
+
+// getBatchSize calculates how many rows are inserted at once,
+// keeping the number of placeholders of a batch under the mysql limit
+func getBatchSize(bulkInsertSize int, colCount int) int {
+	size := bulkInsertSize
+	if colCount > 0 && size*colCount > maxPlaceholders {
+		size = maxPlaceholders / colCount
+	}
+
+	if size < 1 {
+		size = 1
+	}
+
+	return size
+}
+
+// getRowSize estimates the number of bytes a row takes in an insert packet
+func getRowSize(row []interface{}) int {
+	size := 0
+	for _, value := range row {
+		size += valueOverhead
+		if v, ok := value.(string); ok {
+			size += len(v)
+		}
+	}
+
+	return size
+}
+
+// getInsertSql creates a multi-row insert statement with placeholders for rowCount rows
+func getInsertSql(table string, cols []string, rowCount int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?,", len(cols)), ",") + ")"
+	values := strings.TrimSuffix(strings.Repeat(row+",\n", rowCount), ",\n")
+
+	return fmt.Sprintf("insert into %v (%v) values\n%v", quoteName(table), strings.Join(quoteNames(cols), ","), values)
+}
+
+// prepareInsert prepares the insert statement used for full batches
+func (s *DbService) prepareInsert(ctx context.Context) error {
+	s.batchSize = getBatchSize(s.config.BulkInsertSize, len(s.cols))
+	s.rows = make([][]interface{}, 0, s.batchSize)
+
+	stmt, err := s.db.PreparexContext(ctx, getInsertSql(s.config.Table, s.cols, s.batchSize))
+	if err != nil {
+		return err
+	}
+	s.insertStmt = stmt
+
+	return nil
+}
+
+// insertOutstandingRows hands all collected rows up to this point to the writers
+func (s *DbService) insertOutstandingRows() error {
+	if len(s.rows) == 0 {
+		return nil
+	}
+
+	err := s.writers.send(s.rows)
+	if err != nil {
+		return err
+	}
+
+	// the sent rows now belong to the writers, start a new batch
+	s.rows = make([][]interface{}, 0, s.batchSize)
+	s.batchBytes = 0
+	return nil
+}
+
+// insertBatch inserts a batch of rows to db, it is called by the writers.
+// Transient errors, such as deadlocks, are retried.
+func (s *DbService) insertBatch(ctx context.Context, rows [][]interface{}) error {
+	args := make([]interface{}, 0, len(rows)*len(s.cols))
+	for _, row := range rows {
+		args = append(args, row...)
+	}
+
+	return s.withRetry(ctx, func() error {
+		return s.insertBatchTx(ctx, rows, args)
+	})
+}
+
+// insertBatchTx inserts a batch of rows in its own transaction,
+// so a failed or cancelled batch is rolled back entirely and can be replayed
+func (s *DbService) insertBatchTx(ctx context.Context, rows [][]interface{}, args []interface{}) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(rows) == s.batchSize {
+		_, err = tx.StmtxContext(ctx, s.insertStmt).ExecContext(ctx, args...)
+	} else {
+		// incomplete batch
+		_, err = tx.ExecContext(ctx, getInsertSql(s.config.Table, s.cols, len(rows)), args...)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil && !isServerError(err) {
+		// the connection was lost, we don't know whether the batch was committed
+		return notReplayableError{err}
+	}
+
+	return err
+}
@@ -0,0 +1,78 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBatchSize(t *testing.T) {
+	assert.Equal(t, getBatchSize(10000, 5), 10000)
+	assert.Equal(t, getBatchSize(10000, 10), 6553)
+	assert.Equal(t, getBatchSize(10000, 100000), 1)
+	assert.Equal(t, getBatchSize(0, 5), 1)
+}
+
+func TestGetInsertSql(t *testing.T) {
+	assert.Equal(t, getInsertSql("readings", []string{"no_id", "reading"}, 2),
+		"insert into `readings` (`no_id`,`reading`) values\n(?,?),\n(?,?)")
+}
+
+func TestGetRowSize(t *testing.T) {
+	assert.Equal(t, getRowSize([]interface{}{"abc", nil, ""}), 3+3*valueOverhead)
+}
+
+func TestReadConfigMaxPacketRatio(t *testing.T) {
+	s := NewService()
+	v := viper.New()
+
+	for _, ratio := range []float64{0, -0.5, 1.5} {
+		v.Set("maxPacketRatio", ratio)
+		s.config = newConfig()
+		assert.NotNil(t, s.readConfig(v))
+	}
+
+	v.Set("maxPacketRatio", 1)
+	s.config = newConfig()
+	assert.Nil(t, s.readConfig(v))
+	assert.Equal(t, s.config.MaxPacketRatio, 1.0)
+}
+
+func TestProcessLineWidth(t *testing.T) {
+	s := NewService()
+	s.cols = []string{"id", "name"}
+
+	assert.Equal(t, s.ProcessLine([]string{"1", "a", "x"}).Error(), "line has 3 columns, header has 2")
+	assert.NotNil(t, s.ProcessLine([]string{"1"}))
+	assert.Equal(t, s.rejected, 2)
+}
+
+func TestRejectedRows(t *testing.T) {
+	s := NewService()
+	s.cols = []string{"id"}
+	s.batchSize = 2
+	s.maxBatchBytes = 1 << 20
+
+	// the second batch fails
+	batches := 0
+	ctx := context.Background()
+	s.writers = startWriters(ctx, 1, func(ctx context.Context, rows [][]interface{}) error {
+		batches++
+		if batches == 2 {
+			return errors.New("insert failed")
+		}
+		return nil
+	}, false)
+
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		assert.Nil(t, s.ProcessLineContext(ctx, []string{id}))
+	}
+	assert.NotNil(t, s.ProcessLineContext(ctx, []string{"6", "x"}))
+
+	assert.NotNil(t, s.EndContext(ctx))
+	assert.Equal(t, s.rowCount, 2)
+	assert.Equal(t, s.rejected, 4)
+}
@@ -2,11 +2,16 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/spf13/viper"
@@ -25,12 +30,17 @@ const (
 	defaultDrop           = false
 	defaultTruncate       = false
 	defaultBulkInsertSize = 10000
+	defaultMaxPacketRatio = 0.75
+	defaultWriters        = 1
 	defaultColType        = "VARCHAR(255) NULL DEFAULT NULL"
 	defaultTableOptions   = "COLLATE='utf8_general_ci' ENGINE=InnoDB"
 
+	maxDecimalPrecision = 65
+	maxDecimalScale     = 30
+
 	autoPkColType  = "`idauto` INT(11) NOT NULL AUTO_INCREMENT"
 	autoPkColIndex = "PRIMARY KEY(`idauto`)"
-	colIndexTpl    = "INDEX `{col}` (`{col}`)"
+	colIndexTpl    = "INDEX {col} ({col})"
 )
 
 // column types as understood by us
@@ -50,23 +60,48 @@ type Config struct {
 	Username string // db username
 	Password string // db password
 
+	PasswordFile string // file holding the db password, e.g. a Docker or Kubernetes secret, overrides Password
+
+	Connections map[string]Connection // named connections, by name
+	Connection  string                // name of the connection of the file, overrides the connection options above
+
 	Table      string                   // table name
 	Mapping    map[string]ColumnMapping // columns mapping
 	ColumnType map[string]string        // kind of columns type as understood by us (internal)
+	DataTypes  map[string]string        // data types of the columns of typed formats, e.g. parquet (internal)
 
 	Drop     bool // drop table if already exists?
 	Truncate bool // truncate table before insert?
 	AutoPk   bool // use auto increment primary key?
 
-	DefaultColType string // column type definintion
-	TableOptions   string // default table options
-	BulkInsertSize int    // how many rows to insert at once
+	DefaultColType string  // column type definintion
+	TableOptions   string  // default table options
+	BulkInsertSize int     // how many rows to insert at once
+	MaxPacketRatio float64 // max fraction of max_allowed_packet a batch may use
+	Writers        int     // number of goroutines inserting batches in parallel
+
+	RetryAttempts int           // how many times a batch is tried before giving up
+	RetryErrors   []uint16      // mysql error codes for which a batch is retried
+	RetryBackoff  time.Duration // wait before the first retry, doubled after each retry
+	QueryTimeout  time.Duration // timeout of a single query, 0 means no timeout
+
+	AuditTable string // table recording the import history, empty to disable it
 
 	Verbose bool // whether to log various exection steps
 
 	Email csv2table.Email
 }
 
+// Connection holds the options of a named connection, see Config.Connections
+type Connection struct {
+	Host         string
+	Port         int
+	Db           string
+	Username     string
+	Password     string
+	PasswordFile string
+}
+
 // ColumnMapping holds configuration of a csv column
 type ColumnMapping struct {
 	Type        string
@@ -78,14 +113,23 @@ type ColumnMapping struct {
 
 // DbService represents a service that implements csv2table.DbService for mysql
 type DbService struct {
-	db *sqlx.DB // mysql connection
-
-	fileName string // name of currently processed file
-	config   Config // config for this file
-
-	cols       []string // column names for current file
-	rowCount   int      // number of rows currently processed
-	statements []string // current list of sql statements, one for each row
+	db   *sqlx.DB // mysql connection
+	pool *Pool    // pool sharing the connection, nil if owned by the service
+
+	fileName     string       // name of currently processed file
+	config       Config       // config for this file
+	floatParsers floatParsers // float parsers prepared for this service
+
+	cols     []string        // column names for current file
+	rowCount int             // number of rows currently processed
+	rejected int             // number of rows not inserted: invalid lines and the rows of failed batches
+	rows     [][]interface{} // current list of rows waiting to be inserted
+
+	batchSize     int        // number of rows inserted at once
+	batchBytes    int        // estimated size in bytes of the current rows
+	maxBatchBytes int        // max size in bytes of a batch, derived from max_allowed_packet
+	insertStmt    *sqlx.Stmt // prepared insert statement for a full batch
+	writers       *writers   // background goroutines executing the inserts
 }
 
 // newConfig creates a new Config and applies defaults
@@ -96,6 +140,11 @@ func newConfig() Config {
 		Drop:           defaultDrop,
 		Truncate:       defaultTruncate,
 		BulkInsertSize: defaultBulkInsertSize,
+		MaxPacketRatio: defaultMaxPacketRatio,
+		Writers:        defaultWriters,
+		RetryAttempts:  defaultRetryAttempts,
+		RetryErrors:    defaultRetryErrors,
+		RetryBackoff:   defaultRetryBackoff,
 		DefaultColType: defaultColType,
 		TableOptions:   defaultTableOptions,
 	}
@@ -105,20 +154,65 @@ func newConfig() Config {
 
 // NewService creates a new instance of the DbService
 func NewService() *DbService {
-	return &DbService{}
+	return &DbService{
+		floatParsers: make(floatParsers),
+	}
 }
 
 // Start initializes the processing of a csv file
 func (s *DbService) Start(fileName string, v *viper.Viper) error {
+	return s.StartContext(context.Background(), fileName, v)
+}
+
+// End finishes the processing of a csv2table.CsvFile
+func (s *DbService) End() error {
+	return s.EndContext(context.Background())
+}
+
+// ProcessHeader is called to process the header, after Start() and before first call of ProcessLine()
+func (s *DbService) ProcessHeader(header []string) error {
+	return s.ProcessHeaderContext(context.Background(), header)
+}
+
+// ProcessLine processes a line header of the csv file
+func (s *DbService) ProcessLine(line []string) error {
+	return s.ProcessLineContext(context.Background(), line)
+}
+
+// StartContext is like Start, using ctx for all database operations
+func (s *DbService) StartContext(ctx context.Context, fileName string, v *viper.Viper) error {
 	s.fileName = fileName
 
 	// read config
 	s.config = newConfig()
 
 	// default table name is csv file name
-	baseName := strings.Replace(fileName, ".csv", "", -1)
-	s.config.Table = csv2table.SanitizeName(baseName)
+	s.config.Table = csv2table.DefaultTableName(fileName)
+
+	err := s.readConfig(v)
+	if err != nil {
+		return err
+	}
+
+	if s.config.Verbose {
+		log.Printf("Start importing %s\n", fileName)
+	}
+
+	err = s.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// initial row counts
+	s.rowCount = 0
+	s.rejected = 0
+
+	return nil
+}
 
+// readConfig unmarshals the configuration of v into s.config, resolving the named connection and reading
+// the password file, if any
+func (s *DbService) readConfig(v *viper.Viper) error {
 	if v != nil {
 		err := v.Unmarshal(&s.config)
 		if err != nil {
@@ -126,55 +220,80 @@ func (s *DbService) Start(fileName string, v *viper.Viper) error {
 		}
 	}
 
-	if s.config.Verbose {
-		log.Printf("Start importing %s\n", fileName)
+	err := checkMaxPacketRatio(s.config.MaxPacketRatio)
+	if err != nil {
+		return err
 	}
 
-	err := s.connect()
+	err = resolveConnection(&s.config)
 	if err != nil {
 		return err
 	}
 
-	// escape all names (columns and table name)
-	s.config.Table = escapeString(s.config.Table)
+	if s.config.PasswordFile != "" {
+		password, err := csv2table.ReadPasswordFile(s.config.PasswordFile)
+		if err != nil {
+			return err
+		}
+		s.config.Password = password
+	}
 
-	// allocate statements slice
-	s.statements = make([]string, 0, s.config.BulkInsertSize)
+	return nil
+}
 
-	// initial row count
-	s.rowCount = 0
+// checkMaxPacketRatio checks that a batch may use a part of max_allowed_packet: with a ratio of 0 or less
+// every row would be its own insert, above 1 the batches would exceed the packet size
+func checkMaxPacketRatio(ratio float64) error {
+	if ratio <= 0 || ratio > 1 {
+		return errors.New("maxPacketRatio must be greater than 0 and at most 1")
+	}
 
 	return nil
 }
 
-// End finishes the processing of a csv2table.CsvFile
-func (s *DbService) End() error {
+// EndContext is like End. If ctx is cancelled the outstanding rows are not inserted,
+// only the batches already committed remain in the table.
+func (s *DbService) EndContext(ctx context.Context) error {
 	defer func() {
-		if s.db != nil {
-			s.db.Close()
-			s.db = nil
+		s.rows = nil
+		if s.insertStmt != nil {
+			s.insertStmt.Close()
+			s.insertStmt = nil
 		}
+		s.release()
 	}()
 
-	// insert any outstanding rows
-	if len(s.statements) > 0 {
-		err := s.insertOutstandingRows()
-		if err != nil {
-			return err
-		}
+	// End can be called before ProcessHeader, or more than once
+	if s.writers == nil {
+		return nil
 	}
 
-	return nil
+	// insert any outstanding rows and wait for the writers to finish
+	err := ctx.Err()
+	if err == nil {
+		err = s.insertOutstandingRows()
+	}
+	werr := s.writers.stop()
+
+	// the rows still waiting were not sent, because of a failure or cancellation
+	s.rowCount = s.writers.inserted()
+	s.rejected += s.writers.rejectedRows() + len(s.rows)
+	s.writers = nil
+
+	if err == nil {
+		err = werr
+	}
+	return err
 }
 
-// ProcessHeader is called to process the header, after Start() and before first call of ProcessLine()
-func (s *DbService) ProcessHeader(header []string) error {
+// ProcessHeaderContext is like ProcessHeader, using ctx for all database operations.
+// The same ctx is used by the writers for the inserts of this file.
+func (s *DbService) ProcessHeaderContext(ctx context.Context, header []string) error {
 	// extract columns names from header
 	s.cols = csv2table.SanitizeNames(header)
-	s.cols = escapeStrings(s.cols)
 
 	// prepare table
-	exists, err := s.tableExists()
+	exists, err := s.tableExists(ctx)
 	if err != nil {
 		return err
 	}
@@ -185,7 +304,7 @@ func (s *DbService) ProcessHeader(header []string) error {
 			log.Printf("Dropping table %v\n", s.config.Table)
 		}
 
-		_, err = s.db.Exec("drop table " + s.config.Table)
+		err = s.exec(ctx, "drop table "+quoteName(s.config.Table))
 		if err != nil {
 			return err
 		}
@@ -200,7 +319,7 @@ func (s *DbService) ProcessHeader(header []string) error {
 			log.Printf("Truncating table %v\n", s.config.Table)
 		}
 
-		_, err = s.db.Exec("truncate table " + s.config.Table)
+		err = s.exec(ctx, "truncate table "+quoteName(s.config.Table))
 		if err != nil {
 			return err
 		}
@@ -208,7 +327,7 @@ func (s *DbService) ProcessHeader(header []string) error {
 
 	// CREATE table if not exists
 	if !exists {
-		err = s.createTable()
+		err = s.createTable(ctx)
 		if err != nil {
 			return err
 		}
@@ -220,8 +339,12 @@ func (s *DbService) ProcessHeader(header []string) error {
 		return err
 	}
 
-	// allocate statements slice
-	s.statements = make([]string, 0, s.config.BulkInsertSize)
+	// prepare the insert statement used for full batches
+	err = s.prepareInsert(ctx)
+	if err != nil {
+		return err
+	}
+	s.writers = startWriters(ctx, s.config.Writers, s.insertBatch, s.config.Verbose)
 
 	if s.config.Verbose {
 		log.Printf("Starting import\n")
@@ -230,13 +353,29 @@ func (s *DbService) ProcessHeader(header []string) error {
 	return nil
 }
 
-// ProcessLine processes a line header of the csv file
-func (s *DbService) ProcessLine(line []string) error {
-	var err error
+// ProcessLineContext is like ProcessLine, it stops as soon as ctx is cancelled
+func (s *DbService) ProcessLineContext(ctx context.Context, line []string) error {
+	// the line is rejected unless added to the rows, whose failures are counted by EndContext
+	added := false
+	defer func() {
+		if !added {
+			s.rejected++
+		}
+	}()
+
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	// readers not checking the record width, the insert needs a value for each column
+	if len(line) != len(s.cols) {
+		return fmt.Errorf("line has %d columns, header has %d", len(line), len(s.cols))
+	}
 
 	// final column values slice
-	// use pointer in order to use nil  to describe mysql NULL
-	data := make([]*string, 0, len(s.cols))
+	// use nil to describe mysql NULL
+	data := make([]interface{}, 0, len(s.cols))
 
 	for i, value := range line {
 		col := s.cols[i]
@@ -246,11 +385,26 @@ func (s *DbService) ProcessLine(line []string) error {
 		}
 
 		// add value
-		data = append(data, mysqlValue)
+		if mysqlValue == nil {
+			data = append(data, nil)
+		} else {
+			data = append(data, *mysqlValue)
+		}
 	}
 
-	s.statements = append(s.statements, s.getSqlStringForRow(data))
-	if len(s.statements) == s.config.BulkInsertSize {
+	// flush first if this row would make the batch exceed the packet size
+	size := getRowSize(data)
+	if len(s.rows) > 0 && s.batchBytes+size > s.maxBatchBytes {
+		err = s.insertOutstandingRows()
+		if err != nil {
+			return err
+		}
+	}
+
+	s.rows = append(s.rows, data)
+	s.batchBytes += size
+	added = true
+	if len(s.rows) == s.batchSize {
 		err = s.insertOutstandingRows()
 		if err != nil {
 			return err
@@ -261,21 +415,85 @@ func (s *DbService) ProcessLine(line []string) error {
 }
 
 // connect connects to the database
-func (s *DbService) connect() error {
+func (s *DbService) connect(ctx context.Context) error {
 	var err error
-	s.db, err = sqlx.Open("mysql", s.config.Username+":"+s.config.Password+"@/"+s.config.Db)
+	if s.pool != nil {
+		s.db, err = s.pool.open(s.dsn())
+	} else {
+		s.db, err = sqlx.Open("mysql", s.dsn())
+	}
 	if err != nil {
 		return err
 	}
 
 	// ping it, to make sure db details are valid
-	return s.db.Ping()
+	err = s.db.PingContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// batches must fit in a mysql packet
+	var maxPacket int
+	err = s.db.QueryRowxContext(ctx, "SELECT @@max_allowed_packet").Scan(&maxPacket)
+	if err != nil {
+		return err
+	}
+	s.maxBatchBytes = int(float64(maxPacket) * s.config.MaxPacketRatio)
+
+	return nil
+}
+
+// release releases the connection, closing it unless it is shared by a pool
+func (s *DbService) release() {
+	if s.db != nil && s.pool == nil {
+		s.db.Close()
+	}
+	s.db = nil
+}
+
+// dsn returns the data source name of the configured connection, the local server by default
+func (s *DbService) dsn() string {
+	address := ""
+	if s.config.Host != "" {
+		address = "tcp(" + net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port)) + ")"
+	}
+
+	return s.config.Username + ":" + s.config.Password + "@" + address + "/" + s.config.Db
+}
+
+// resolveConnection replaces the connection options of config by the ones of its named connection, if any
+func resolveConnection(config *Config) error {
+	if config.Connection == "" {
+		return nil
+	}
+
+	// viper keys are in lower case
+	conn, exists := config.Connections[strings.ToLower(config.Connection)]
+	if !exists {
+		return fmt.Errorf("unknown connection %s", config.Connection)
+	}
+
+	config.Host = conn.Host
+	config.Port = conn.Port
+	if config.Port == 0 {
+		config.Port = defaultPort
+	}
+	config.Db = conn.Db
+	config.Username = conn.Username
+	config.Password = conn.Password
+	config.PasswordFile = conn.PasswordFile
+
+	return nil
 }
 
 // tableExists check if a table exists
-func (s *DbService) tableExists() (bool, error) {
+func (s *DbService) tableExists(ctx context.Context) (bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+
 	var exists string
-	var err = s.db.QueryRowx(fmt.Sprintf("SHOW TABLES LIKE '%v'", s.config.Table)).Scan(&exists)
+	var err = s.db.QueryRowxContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?",
+		s.config.Table).Scan(&exists)
 	if err != nil && err != sql.ErrNoRows {
 		return false, err
 	}
@@ -289,12 +507,12 @@ func (s *DbService) tableExists() (bool, error) {
 }
 
 // createTable creates the destination table
-func (s *DbService) createTable() error {
+func (s *DbService) createTable(ctx context.Context) error {
 	if s.config.Verbose {
 		log.Printf("Creating table %v\n", s.config.Table)
 	}
 
-	sql := fmt.Sprintf("create table `%v` (\n", s.config.Table)
+	sql := fmt.Sprintf("create table %v (\n", quoteName(s.config.Table))
 
 	// add auto-increment PK
 	if s.config.AutoPk {
@@ -306,11 +524,11 @@ func (s *DbService) createTable() error {
 	// add column definitions
 	for _, col := range s.cols {
 		mapping := s.getColMapping(col)
-		sql += fmt.Sprintf("`%v` %v, \n", col, mapping.Type)
+		sql += fmt.Sprintf("%v %v, \n", quoteName(col), mapping.Type)
 
 		// build indexes
 		if mapping.Index {
-			indexes = append(indexes, strings.Replace(colIndexTpl, "{col}", col, -1))
+			indexes = append(indexes, strings.Replace(colIndexTpl, "{col}", quoteName(col), -1))
 		}
 	}
 
@@ -329,95 +547,98 @@ func (s *DbService) createTable() error {
 	// add table options
 	sql += s.config.TableOptions
 
-	_, err := s.db.Exec(sql)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.exec(ctx, sql)
 }
 
-// getSqlStringForRow creates an sql values string for insert
-func (s *DbService) getSqlStringForRow(data []*string) string {
-	mysqlData := make([]string, len(data))
-
-	for i, value := range data {
-		sqlv := "NULL"
-		if value != nil {
-			sqlv = fmt.Sprintf("'%s'", escapeString(*value))
-		}
-
-		mysqlData[i] = sqlv
-	}
+// exec executes a query that doesn't return rows, applying the configured query timeout
+func (s *DbService) exec(ctx context.Context, query string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 
-	return "(" + strings.Join(mysqlData, ",") + ")\n"
+	_, err := s.db.ExecContext(ctx, query)
+	return err
 }
 
-// insertOutstandingRows inserts to db all collected rows up to this point
-func (s *DbService) insertOutstandingRows() error {
-	if len(s.statements) == 0 {
-		return nil
-	}
-
-	cols := strings.Join(s.cols, ",")
-	data := strings.Join(s.statements, ",")
+// getColMapping creates the sql snippet for a column definition
+// if not defined, use the default mapping
+func (s *DbService) getColMapping(col string) ColumnMapping {
+	mapping := s.config.Mapping[col]
 
-	sql := fmt.Sprintf("insert into `%v` (%v) values\n %v", s.config.Table, cols, data)
-	_, err := s.db.Exec(sql)
-	if err != nil {
-		return err
+	// set required default fields if not set
+	if mapping.Type == "" {
+		mapping.Type = s.dataColType(col)
 	}
 
-	s.rowCount += len(s.statements)
-	if s.config.Verbose {
-		log.Printf("Inserted %v rows\n", s.rowCount)
-	}
+	return mapping
+}
 
-	// empty statements
-	s.statements = s.statements[:0]
-	return nil
+// dataColTypes holds the column definitions of the data types of typed formats
+var dataColTypes = map[string]string{
+	csv2table.DataTypeBinary:   "BLOB NULL DEFAULT NULL",
+	csv2table.DataTypeBool:     "TINYINT(1) NULL DEFAULT NULL",
+	csv2table.DataTypeInt:      "INT NULL DEFAULT NULL",
+	csv2table.DataTypeBigInt:   "BIGINT NULL DEFAULT NULL",
+	csv2table.DataTypeFloat:    "FLOAT NULL DEFAULT NULL",
+	csv2table.DataTypeDouble:   "DOUBLE NULL DEFAULT NULL",
+	csv2table.DataTypeDate:     "DATE NULL DEFAULT NULL",
+	csv2table.DataTypeTime:     "TIME(6) NULL DEFAULT NULL",
+	csv2table.DataTypeDateTime: "DATETIME(6) NULL DEFAULT NULL",
 }
 
-// getColMapping creates the sql snippet for a column definition
-// if not defined, use the default mapping
-func (s *DbService) getColMapping(col string) ColumnMapping {
-	mapping, exists := s.config.Mapping[col]
-	if !exists {
-		mapping = ColumnMapping{Type: s.config.DefaultColType, Index: false}
+// dataColType returns the default column definition of a column, matching its data type if
+// the file has typed columns. Strings and decimals too large for mysql use the default column type.
+func (s *DbService) dataColType(col string) string {
+	dataType := s.config.DataTypes[col]
+	if colType, exists := dataColTypes[dataType]; exists {
+		return colType
 	}
 
-	// set required default fields if not set
-	if mapping.Type == "" {
-		mapping.Type = s.config.DefaultColType
+	var precision, scale int
+	_, err := fmt.Sscanf(dataType, "decimal(%d,%d)", &precision, &scale)
+	if err == nil && precision <= maxDecimalPrecision && scale <= maxDecimalScale {
+		return fmt.Sprintf("DECIMAL(%d,%d) NULL DEFAULT NULL", precision, scale)
 	}
 
-	return mapping
+	return s.config.DefaultColType
+}
+
+// isTypedColumn checks whether a column has a data type other than string
+func (s *DbService) isTypedColumn(col string) bool {
+	dataType := s.config.DataTypes[col]
+	return dataType != "" && dataType != csv2table.DataTypeString
 }
 
 // parseAndSetDbTypes parses current table metadata and update Config.ColumnType with equivalent types understood by us
 func (s *DbService) parseAndSetDbTypes() error {
 	s.config.ColumnType = make(map[string]string)
 
-	rInt := regexp.MustCompile("(?i)int|unsigned|bit|tinyint|smallint|mediumint")
-	rFloat := regexp.MustCompile("(?i)float|double")
-	rDate := regexp.MustCompile("(?i)date")
-	rDateTime := regexp.MustCompile("(?i)datetime|timestamp")
-
 	for _, col := range s.cols {
 		mapping := s.getColMapping(col)
-
-		if rInt.MatchString(mapping.Type) {
-			s.config.ColumnType[col] = typeInt
-		} else if rFloat.MatchString(mapping.Type) {
-			s.config.ColumnType[col] = typeFloat
-		} else if rDateTime.MatchString(mapping.Type) {
-			s.config.ColumnType[col] = typeDateTime
-		} else if rDate.MatchString(mapping.Type) {
-			s.config.ColumnType[col] = typeDate
-		} else {
-			s.config.ColumnType[col] = typeString // default
-		}
+		s.config.ColumnType[col] = columnType(mapping.Type)
 	}
 
 	return nil
 }
+
+// column type patterns, matched against column definitions
+var (
+	rInt      = regexp.MustCompile("(?i)int|unsigned|bit|tinyint|smallint|mediumint")
+	rFloat    = regexp.MustCompile("(?i)float|double")
+	rDate     = regexp.MustCompile("(?i)date")
+	rDateTime = regexp.MustCompile("(?i)datetime|timestamp")
+)
+
+// columnType returns the kind of a column definition, as understood by us
+func columnType(definition string) string {
+	if rInt.MatchString(definition) {
+		return typeInt
+	} else if rFloat.MatchString(definition) {
+		return typeFloat
+	} else if rDateTime.MatchString(definition) {
+		return typeDateTime
+	} else if rDate.MatchString(definition) {
+		return typeDate
+	}
+
+	return typeString // default
+}
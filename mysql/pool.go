@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Pool shares the database connections of the services it creates, by connection settings, so that
+// the files imported into the same database reuse the connections of the previous files.
+// The services created by NewService instead open their connection in Start and close it in End.
+type Pool struct {
+	mu  sync.Mutex
+	dbs map[string]*sqlx.DB // connections by data source name
+}
+
+// NewPool creates a new connection pool
+func NewPool() *Pool {
+	return &Pool{
+		dbs: make(map[string]*sqlx.DB),
+	}
+}
+
+// NewService creates a new instance of the DbService using the connections of the pool
+func (p *Pool) NewService() *DbService {
+	s := NewService()
+	s.pool = p
+
+	return s
+}
+
+// open returns the connection of a data source name, opening it the first time
+func (p *Pool) open(dsn string) (*sqlx.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if db, exists := p.dbs[dsn]; exists {
+		return db, nil
+	}
+
+	db, err := sqlx.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	p.dbs[dsn] = db
+
+	return db, nil
+}
+
+// Close closes the connections of the pool. The pool can still be used, opening the connections again.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	for dsn, db := range p.dbs {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+		delete(p.dbs, dsn)
+	}
+
+	return err
+}
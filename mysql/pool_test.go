@@ -0,0 +1,66 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	p := NewPool()
+
+	// the connections are opened lazily, no server is needed
+	db1, err := p.open("user:pwd@tcp(reporting:3306)/sales")
+	assert.Nil(t, err)
+	db2, err := p.open("user:pwd@tcp(reporting:3306)/sales")
+	assert.Nil(t, err)
+	db3, err := p.open("user:pwd@tcp(ops:3306)/stock")
+	assert.Nil(t, err)
+	assert.True(t, db1 == db2)
+	assert.False(t, db1 == db3)
+
+	// the shared connections are not closed by the services
+	s := p.NewService()
+	s.db = db1
+	s.release()
+	assert.Nil(t, s.db)
+	assert.Equal(t, len(p.dbs), 2)
+
+	assert.Nil(t, p.Close())
+	assert.Equal(t, len(p.dbs), 0)
+}
+
+func TestReadConfigConnection(t *testing.T) {
+	v := viper.New()
+	v.Set("host", "localhost")
+	v.Set("db", "default_db")
+	v.Set("connections", map[string]interface{}{
+		"reporting": map[string]interface{}{"host": "reporting.local", "db": "reports", "username": "etl", "password": "secret"},
+		"ops":       map[string]interface{}{"host": "ops.local", "port": 3307, "db": "ops", "username": "etl"},
+	})
+
+	// the connection options by default
+	s := NewService()
+	s.config = newConfig()
+	assert.Nil(t, s.readConfig(v))
+	assert.Equal(t, s.dsn(), ":@tcp(localhost:3306)/default_db")
+
+	v.Set("connection", "Reporting")
+	s.config = newConfig()
+	assert.Nil(t, s.readConfig(v))
+	assert.Equal(t, s.dsn(), "etl:secret@tcp(reporting.local:3306)/reports")
+
+	v.Set("connection", "ops")
+	s.config = newConfig()
+	assert.Nil(t, s.readConfig(v))
+	assert.Equal(t, s.dsn(), "etl:@tcp(ops.local:3307)/ops")
+
+	v.Set("connection", "archive")
+	s.config = newConfig()
+	assert.NotNil(t, s.readConfig(v))
+
+	// the local server without host
+	s.config = Config{Username: "root", Password: "pwd", Db: "test"}
+	assert.Equal(t, s.dsn(), "root:pwd@/test")
+}
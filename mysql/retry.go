@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"syscall"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// default retry options
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 500 * time.Millisecond
+)
+
+// defaultRetryErrors are the mysql error codes retried by default: lock wait timeout and deadlock
+var defaultRetryErrors = []uint16{1205, 1213}
+
+// notReplayableError wraps an error after which a batch must not be inserted again,
+// e.g. a connection lost while committing, when the batch may have been committed already
+type notReplayableError struct {
+	err error
+}
+
+func (e notReplayableError) Error() string {
+	return e.err.Error()
+}
+
+func (e notReplayableError) Unwrap() error {
+	return e.err
+}
+
+// isServerError checks whether err was returned by the mysql server,
+// as opposed to a client or connection error
+func isServerError(err error) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	return errors.As(err, &mysqlErr)
+}
+
+// queryContext returns the context used for a single query, applying the configured query timeout
+func (s *DbService) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.config.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.config.QueryTimeout)
+}
+
+// retryable checks whether an error is transient, in which case the failed operation can be replayed
+func (s *DbService) retryable(err error) bool {
+	var notReplayable notReplayableError
+	if errors.As(err, &notReplayable) {
+		return false
+	}
+
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		for _, code := range s.config.RetryErrors {
+			if mysqlErr.Number == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	// connection resets and query timeouts
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysqldriver.ErrInvalidConn) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// withRetry calls fn until it succeeds, returns a non transient error or the attempts are exhausted.
+// The wait between attempts starts at RetryBackoff and doubles after each attempt.
+// fn must be safe to replay, e.g. a transaction that is rolled back on failure.
+func (s *DbService) withRetry(ctx context.Context, fn func() error) error {
+	backoff := s.config.RetryBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= s.config.RetryAttempts || ctx.Err() != nil || !s.retryable(err) {
+			return err
+		}
+
+		if s.config.Verbose {
+			log.Printf("Attempt %v failed, retrying in %v: %v\n", attempt, backoff, err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
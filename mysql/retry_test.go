@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryable(t *testing.T) {
+	s := &DbService{config: newConfig()}
+
+	assert.True(t, s.retryable(&mysqldriver.MySQLError{Number: 1213}))
+	assert.True(t, s.retryable(&mysqldriver.MySQLError{Number: 1205}))
+	assert.False(t, s.retryable(&mysqldriver.MySQLError{Number: 1062}))
+	assert.True(t, s.retryable(driver.ErrBadConn))
+	assert.False(t, s.retryable(notReplayableError{driver.ErrBadConn}))
+	assert.False(t, s.retryable(errors.New("syntax error")))
+}
+
+func TestWithRetry(t *testing.T) {
+	s := &DbService{config: newConfig()}
+	s.config.RetryBackoff = 0
+
+	// succeeds on the last attempt
+	calls := 0
+	err := s.withRetry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return &mysqldriver.MySQLError{Number: 1213}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, calls, 3)
+
+	// attempts exhausted
+	calls = 0
+	err = s.withRetry(context.Background(), func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+	assert.Equal(t, err, driver.ErrBadConn)
+	assert.Equal(t, calls, defaultRetryAttempts)
+
+	// not retried
+	calls = 0
+	err = s.withRetry(context.Background(), func() error {
+		calls++
+		return &mysqldriver.MySQLError{Number: 1062}
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, calls, 1)
+}
@@ -0,0 +1,133 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/schiorean/csv2table"
+	"github.com/spf13/viper"
+)
+
+// DecodeConfig decodes the options of a configuration file, returning the options it doesn't know
+func (s *DbService) DecodeConfig(settings map[string]interface{}) ([]string, error) {
+	config := newConfig()
+	return csv2table.DecodeSettings(settings, &config)
+}
+
+// ValidateConfig checks the merged configuration of a file: value ranges, the password files, the named connection,
+// and the column mappings, whose columns must be in header, if known, and whose formats must match the column types
+func (s *DbService) ValidateConfig(v *viper.Viper, header []string) []*csv2table.ConfigError {
+	// the invalid types are reported by DecodeConfig, for each configuration file
+	config := newConfig()
+	v.Unmarshal(&config)
+
+	var errs []*csv2table.ConfigError
+	invalid := func(key string, err error) {
+		errs = append(errs, &csv2table.ConfigError{Key: key, Err: err})
+	}
+
+	if config.Port < 1 || config.Port > 65535 {
+		invalid("port", fmt.Errorf("invalid port %d", config.Port))
+	}
+	if config.BulkInsertSize < 1 {
+		invalid("bulkinsertsize", errors.New("bulkInsertSize must be at least 1"))
+	}
+	if err := checkMaxPacketRatio(config.MaxPacketRatio); err != nil {
+		invalid("maxpacketratio", err)
+	}
+	if config.Writers < 1 {
+		invalid("writers", errors.New("writers must be at least 1"))
+	}
+	if config.RetryAttempts < 1 {
+		invalid("retryattempts", errors.New("retryAttempts must be at least 1"))
+	}
+	if config.RetryBackoff < 0 {
+		invalid("retrybackoff", errors.New("retryBackoff can't be negative"))
+	}
+	if config.QueryTimeout < 0 {
+		invalid("querytimeout", errors.New("queryTimeout can't be negative"))
+	}
+	if config.PasswordFile != "" {
+		if _, err := csv2table.ReadPasswordFile(config.PasswordFile); err != nil {
+			invalid("passwordfile", err)
+		}
+	}
+
+	names := make([]string, 0, len(config.Connections))
+	for name := range config.Connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conn := config.Connections[name]
+		key := "connections." + name
+		if conn.Port < 0 || conn.Port > 65535 {
+			invalid(key+".port", fmt.Errorf("invalid port %d", conn.Port))
+		}
+		if conn.PasswordFile != "" {
+			if _, err := csv2table.ReadPasswordFile(conn.PasswordFile); err != nil {
+				invalid(key+".passwordfile", err)
+			}
+		}
+	}
+	if err := resolveConnection(&config); err != nil {
+		invalid("connection", err)
+	}
+
+	cols := make(map[string]bool)
+	for _, col := range csv2table.SanitizeNames(header) {
+		cols[col] = true
+	}
+
+	mapped := make([]string, 0, len(config.Mapping))
+	for col := range config.Mapping {
+		mapped = append(mapped, col)
+	}
+	sort.Strings(mapped)
+
+	// column types are resolved like for an import
+	service := &DbService{config: config}
+	for _, col := range mapped {
+		key := "mapping." + col
+		if header != nil && !cols[col] {
+			invalid(key, fmt.Errorf("column %s is not in the header", col))
+		}
+
+		mapping := service.getColMapping(col)
+		if mapping.Format != "" {
+			if err := checkFormat(columnType(mapping.Type), mapping.Format); err != nil {
+				invalid(key+".format", err)
+			}
+		}
+	}
+
+	return errs
+}
+
+// checkFormat checks that a format can be applied to a column type, the way parseType applies it
+func checkFormat(columnType string, format string) error {
+	switch columnType {
+	case typeDate, typeDateTime:
+		// a layout without any element formats any date as itself
+		value := time.Date(2019, 12, 31, 23, 58, 59, 0, time.UTC).Format(format)
+		if value == format {
+			return fmt.Errorf("invalid date format %s, expecting a layout of the reference date, e.g. 02.01.2006", format)
+		}
+
+		_, err := time.Parse(format, value)
+		if err != nil {
+			return fmt.Errorf("invalid date format %s, %v", format, err)
+		}
+	case typeFloat:
+		dp := decimalPoint(format)
+		if dp != "." && dp != "," {
+			return fmt.Errorf("invalid float format %s, expecting a number with a . or , decimal point, e.g. 1,2", format)
+		}
+	default:
+		return fmt.Errorf("format is applied to float and date columns only, not to %s columns", columnType)
+	}
+
+	return nil
+}
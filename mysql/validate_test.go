@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFormat(t *testing.T) {
+	assert.Nil(t, checkFormat(typeDate, "02.01.2006"))
+	assert.Nil(t, checkFormat(typeDateTime, "02.01.2006 15:04:05"))
+	assert.Nil(t, checkFormat(typeFloat, "1,2"))
+	assert.Nil(t, checkFormat(typeFloat, "1.234,5"))
+
+	assert.NotNil(t, checkFormat(typeDate, "dd.mm.yyyy"))
+	assert.NotNil(t, checkFormat(typeFloat, "12"))
+	assert.NotNil(t, checkFormat(typeFloat, "1'2"))
+	assert.NotNil(t, checkFormat(typeString, "02.01.2006"))
+}
+
+func TestDecodeConfig(t *testing.T) {
+	s := NewService()
+	unused, err := s.DecodeConfig(map[string]interface{}{
+		"bulkinsertsize": 100,
+		"mapping":        map[string]interface{}{"channel": map[string]interface{}{"nullif": []interface{}{"Last"}, "nulif": "x"}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, unused, []string{"mapping.channel.nulif"})
+
+	_, err = s.DecodeConfig(map[string]interface{}{"bulkinsertsize": "abc"})
+	assert.NotNil(t, err)
+}
+
+func TestValidateConfig(t *testing.T) {
+	v := viper.New()
+	v.Set("writers", 0)
+	v.Set("mapping", map[string]interface{}{
+		"reading":      map[string]interface{}{"type": "DOUBLE NULL DEFAULT NULL", "format": "1,2"},
+		"reading_date": map[string]interface{}{"type": "DATE NULL DEFAULT NULL", "format": "dd.mm.yyyy"},
+		"channel":      map[string]interface{}{"format": "1,2"},
+		"missing":      map[string]interface{}{"index": true},
+	})
+
+	var keys []string
+	for _, err := range NewService().ValidateConfig(v, []string{"Reading", "Reading_Date", "Channel"}) {
+		keys = append(keys, err.Key)
+	}
+	assert.Equal(t, keys, []string{"writers", "mapping.channel.format", "mapping.missing", "mapping.reading_date.format"})
+
+	// the header is not known
+	keys = nil
+	for _, err := range NewService().ValidateConfig(v, nil) {
+		keys = append(keys, err.Key)
+	}
+	assert.Equal(t, keys, []string{"writers", "mapping.channel.format", "mapping.reading_date.format"})
+}
+
+func TestValidateConfigConnection(t *testing.T) {
+	v := viper.New()
+	v.Set("connections", map[string]interface{}{
+		"ops":       map[string]interface{}{"host": "ops.local", "port": 70000},
+		"reporting": map[string]interface{}{"host": "reporting.local", "passwordFile": "missing"},
+	})
+	v.Set("connection", "archive")
+
+	var keys []string
+	for _, err := range NewService().ValidateConfig(v, nil) {
+		keys = append(keys, err.Key)
+	}
+	assert.Equal(t, keys, []string{"connections.ops.port", "connections.reporting.passwordfile", "connection"})
+}
@@ -0,0 +1,146 @@
+package mysql
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// writers executes insert batches in background goroutines, so that csv parsing and formatting
+// can continue while the database is busy.
+// The batches channel is bounded, which blocks the reader when all writers are busy.
+type writers struct {
+	ctx     context.Context      // cancels all pending inserts
+	batches chan [][]interface{} // batches waiting to be inserted
+	done    chan struct{}        // closed when a writer fails
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	err      error // first error returned by a writer
+	rowCount int   // number of rows inserted by all writers
+	rejected int   // number of rows of the failed or discarded batches
+}
+
+// startWriters starts count writer goroutines that insert batches using the insert function
+func startWriters(ctx context.Context, count int, insert func(ctx context.Context, rows [][]interface{}) error,
+	verbose bool) *writers {
+	if count < 1 {
+		count = 1
+	}
+
+	w := &writers{
+		ctx:     ctx,
+		batches: make(chan [][]interface{}, count),
+		done:    make(chan struct{}),
+	}
+
+	w.wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func() {
+			defer w.wg.Done()
+
+			for rows := range w.batches {
+				// after a failure or cancellation the remaining batches are discarded
+				if w.failed() {
+					w.reject(rows)
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					w.fail(err)
+					w.reject(rows)
+					continue
+				}
+
+				err := insert(ctx, rows)
+				if err != nil {
+					w.fail(err)
+					w.reject(rows)
+					continue
+				}
+
+				w.mu.Lock()
+				w.rowCount += len(rows)
+				if verbose {
+					log.Printf("Inserted %v rows\n", w.rowCount)
+				}
+				w.mu.Unlock()
+			}
+		}()
+	}
+
+	return w
+}
+
+// send queues a batch for insertion, blocking while the queue is full.
+// It returns the writers error if a writer already failed.
+func (w *writers) send(rows [][]interface{}) error {
+	select {
+	case w.batches <- rows:
+		return nil
+	case <-w.done:
+		return w.error()
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
+
+// stop waits for all queued batches to be inserted and returns the first writer error
+func (w *writers) stop() error {
+	close(w.batches)
+	w.wg.Wait()
+
+	return w.error()
+}
+
+// fail records the first writer error and signals the other goroutines
+func (w *writers) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err == nil {
+		w.err = err
+		close(w.done)
+	}
+}
+
+// reject counts the rows of a batch that is not inserted
+func (w *writers) reject(rows [][]interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rejected += len(rows)
+}
+
+// failed checks whether a writer already failed
+func (w *writers) failed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// error returns the first writer error
+func (w *writers) error() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err
+}
+
+// inserted returns the number of rows inserted so far
+func (w *writers) inserted() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.rowCount
+}
+
+// rejectedRows returns the number of rows of the failed or discarded batches
+func (w *writers) rejectedRows() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.rejected
+}
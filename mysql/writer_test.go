@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriters(t *testing.T) {
+	w := startWriters(context.Background(), 3, func(ctx context.Context, rows [][]interface{}) error { return nil }, false)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, w.send(make([][]interface{}, 5)))
+	}
+
+	assert.Nil(t, w.stop())
+	assert.Equal(t, w.inserted(), 50)
+}
+
+func TestWritersError(t *testing.T) {
+	errInsert := errors.New("insert failed")
+	w := startWriters(context.Background(), 2, func(ctx context.Context, rows [][]interface{}) error {
+		if len(rows) == 2 {
+			return errInsert
+		}
+		return nil
+	}, false)
+
+	assert.Nil(t, w.send(make([][]interface{}, 1)))
+	assert.Nil(t, w.send(make([][]interface{}, 2)))
+	sent := 3
+
+	// once failed, sending eventually reports the error
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = w.send(make([][]interface{}, 1))
+		if err == nil {
+			sent++
+		}
+	}
+
+	assert.Equal(t, err, errInsert)
+	assert.Equal(t, w.stop(), errInsert)
+
+	// the failed batch and the discarded ones are rejected
+	assert.True(t, w.rejectedRows() >= 2)
+	assert.Equal(t, w.inserted()+w.rejectedRows(), sent)
+}
+
+func TestWritersCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := startWriters(ctx, 1, func(ctx context.Context, rows [][]interface{}) error { return nil }, false)
+
+	cancel()
+
+	// once cancelled, sending eventually reports the cancellation
+	var err error
+	sent := 0
+	for i := 0; i < 100 && err == nil; i++ {
+		err = w.send(make([][]interface{}, 1))
+		if err == nil {
+			sent++
+		}
+	}
+
+	assert.Equal(t, err, context.Canceled)
+	w.stop()
+	assert.Equal(t, w.inserted(), 0)
+	assert.Equal(t, w.rejectedRows(), sent)
+}
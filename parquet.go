@@ -0,0 +1,412 @@
+package csv2table
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+// parquetColumn is a leaf column of a parquet schema
+type parquetColumn struct {
+	path     string // path of the column in the schema, as used by the column reader
+	name     string // nested names are joined with "_"
+	dataType string
+	format   func(value interface{}) string // converts a value to its text
+}
+
+// parquetReader reads the rows of a parquet file, one row group at a time.
+// Parquet files are read from their end, so the data is copied to a temporary file first.
+type parquetReader struct {
+	tmp      string
+	pr       *reader.ParquetReader
+	columns  []parquetColumn
+	header   bool            // header returned
+	rowGroup int             // next row group
+	values   [][]interface{} // values of the current row group, by column
+	row      int             // next row of the current row group
+}
+
+// newParquetReader creates a parquet reader. Nested columns are flattened, repeated columns are not supported.
+func newParquetReader(r io.Reader) (*parquetReader, error) {
+	f, err := ioutil.TempFile("", "csv2table-*.parquet")
+	if err != nil {
+		return nil, err
+	}
+	p := &parquetReader{tmp: f.Name()}
+
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	pf, err := local.NewLocalFileReader(p.tmp)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	p.pr, err = reader.NewParquetColumnReader(pf, 1)
+	if err != nil {
+		pf.Close()
+		p.Close()
+		return nil, fmt.Errorf("invalid parquet file, %v", err)
+	}
+	pf.Close()
+
+	sh := p.pr.SchemaHandler
+	for _, path := range sh.ValueColumns {
+		names := common.StrToPath(sh.InPathToExPath[path])[1:]
+		name := SanitizeName(strings.Join(names, "_"))
+
+		maxRepetition, err := sh.MaxRepetitionLevel(common.StrToPath(path))
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		if maxRepetition > 0 {
+			p.Close()
+			return nil, fmt.Errorf("column %s is repeated, lists are not supported", name)
+		}
+
+		column := newParquetColumn(sh.SchemaElements[sh.MapIndex[path]])
+		column.path = path
+		column.name = name
+		p.columns = append(p.columns, column)
+	}
+
+	return p, nil
+}
+
+// DataTypes returns the data types of the columns, as found in the schema
+func (p *parquetReader) DataTypes() map[string]string {
+	dataTypes := make(map[string]string)
+	for _, column := range p.columns {
+		dataTypes[column.name] = column.dataType
+	}
+
+	return dataTypes
+}
+
+// Read returns the column names first, then the rows. The values of a row group are read
+// when its first row is returned.
+func (p *parquetReader) Read() ([]string, error) {
+	if !p.header {
+		p.header = true
+
+		header := make([]string, len(p.columns))
+		for i, column := range p.columns {
+			header[i] = column.name
+		}
+		return header, nil
+	}
+
+	for p.values == nil || p.row >= len(p.values[0]) {
+		err := p.readRowGroup()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	record := make([]string, len(p.columns))
+	for i, column := range p.columns {
+		if value := p.values[i][p.row]; value != nil {
+			record[i] = column.format(value)
+		}
+	}
+	p.row++
+
+	return record, nil
+}
+
+// readRowGroup reads the values of the next row group
+func (p *parquetReader) readRowGroup() error {
+	rowGroups := p.pr.Footer.GetRowGroups()
+	if p.rowGroup >= len(rowGroups) {
+		return io.EOF
+	}
+	numRows := rowGroups[p.rowGroup].GetNumRows()
+	p.rowGroup++
+
+	p.values = make([][]interface{}, len(p.columns))
+	p.row = 0
+	if numRows == 0 {
+		return nil
+	}
+
+	for i, column := range p.columns {
+		values, _, _, err := p.pr.ReadColumnByPath(column.path, numRows)
+		if err != nil {
+			return err
+		}
+		if int64(len(values)) != numRows {
+			return fmt.Errorf("column %s has %d values instead of %d", column.name, len(values), numRows)
+		}
+		p.values[i] = values
+	}
+
+	return nil
+}
+
+// Close closes the parquet file and removes the temporary copy
+func (p *parquetReader) Close() error {
+	if p.pr != nil {
+		p.pr.ReadStop()
+		p.pr = nil
+	}
+
+	return os.Remove(p.tmp)
+}
+
+// newParquetColumn maps the logical type of a column, or its converted type for older files,
+// to a data type, along with the conversion of its values
+func newParquetColumn(el *parquet.SchemaElement) parquetColumn {
+	if lt := el.GetLogicalType(); lt != nil {
+		switch {
+		case lt.IsSetSTRING(), lt.IsSetENUM(), lt.IsSetJSON():
+			return parquetColumn{dataType: DataTypeString, format: formatParquetString}
+		case lt.IsSetUUID():
+			return parquetColumn{dataType: DataTypeString, format: formatParquetUUID}
+		case lt.IsSetDECIMAL():
+			return newParquetDecimal(lt.DECIMAL.GetPrecision(), lt.DECIMAL.GetScale())
+		case lt.IsSetDATE():
+			return parquetColumn{dataType: DataTypeDate, format: formatParquetDate}
+		case lt.IsSetTIME():
+			return parquetColumn{dataType: DataTypeTime, format: parquetTime(lt.TIME.GetUnit())}
+		case lt.IsSetTIMESTAMP():
+			return parquetColumn{dataType: DataTypeDateTime, format: parquetTimestamp(lt.TIMESTAMP.GetUnit())}
+		case lt.IsSetINTEGER():
+			return newParquetInteger(int(lt.INTEGER.GetBitWidth()), lt.INTEGER.GetIsSigned())
+		}
+	}
+
+	if el.IsSetConvertedType() {
+		switch el.GetConvertedType() {
+		case parquet.ConvertedType_UTF8, parquet.ConvertedType_ENUM, parquet.ConvertedType_JSON:
+			return parquetColumn{dataType: DataTypeString, format: formatParquetString}
+		case parquet.ConvertedType_DECIMAL:
+			return newParquetDecimal(el.GetPrecision(), el.GetScale())
+		case parquet.ConvertedType_DATE:
+			return parquetColumn{dataType: DataTypeDate, format: formatParquetDate}
+		case parquet.ConvertedType_TIME_MILLIS:
+			return parquetColumn{dataType: DataTypeTime, format: parquetTime(&parquet.TimeUnit{MILLIS: parquet.NewMilliSeconds()})}
+		case parquet.ConvertedType_TIME_MICROS:
+			return parquetColumn{dataType: DataTypeTime, format: parquetTime(&parquet.TimeUnit{MICROS: parquet.NewMicroSeconds()})}
+		case parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return parquetColumn{dataType: DataTypeDateTime, format: parquetTimestamp(&parquet.TimeUnit{MILLIS: parquet.NewMilliSeconds()})}
+		case parquet.ConvertedType_TIMESTAMP_MICROS:
+			return parquetColumn{dataType: DataTypeDateTime, format: parquetTimestamp(&parquet.TimeUnit{MICROS: parquet.NewMicroSeconds()})}
+		case parquet.ConvertedType_UINT_8:
+			return newParquetInteger(8, false)
+		case parquet.ConvertedType_UINT_16:
+			return newParquetInteger(16, false)
+		case parquet.ConvertedType_UINT_32:
+			return newParquetInteger(32, false)
+		case parquet.ConvertedType_UINT_64:
+			return newParquetInteger(64, false)
+		case parquet.ConvertedType_INT_8, parquet.ConvertedType_INT_16, parquet.ConvertedType_INT_32:
+			return newParquetInteger(32, true)
+		case parquet.ConvertedType_INT_64:
+			return newParquetInteger(64, true)
+		}
+	}
+
+	switch el.GetType() {
+	case parquet.Type_BOOLEAN:
+		return parquetColumn{dataType: DataTypeBool, format: formatParquetBool}
+	case parquet.Type_INT32:
+		return newParquetInteger(32, true)
+	case parquet.Type_INT64:
+		return newParquetInteger(64, true)
+	case parquet.Type_INT96:
+		// legacy timestamps
+		return parquetColumn{dataType: DataTypeDateTime, format: formatParquetInt96}
+	case parquet.Type_FLOAT:
+		return parquetColumn{dataType: DataTypeFloat, format: formatParquetFloat}
+	case parquet.Type_DOUBLE:
+		return parquetColumn{dataType: DataTypeDouble, format: formatParquetFloat}
+	}
+
+	return parquetColumn{dataType: DataTypeBinary, format: formatParquetString}
+}
+
+// newParquetInteger returns an integer column. Unsigned integers are stored as signed values
+// of the same width, 64 bit unsigned integers don't fit a bigint.
+func newParquetInteger(bitWidth int, signed bool) parquetColumn {
+	switch {
+	case signed && bitWidth <= 32, !signed && bitWidth <= 16:
+		return parquetColumn{dataType: DataTypeInt, format: formatParquetInteger(signed)}
+	case signed, bitWidth <= 32:
+		return parquetColumn{dataType: DataTypeBigInt, format: formatParquetInteger(signed)}
+	}
+
+	return parquetColumn{dataType: DecimalDataType(20, 0), format: formatParquetInteger(signed)}
+}
+
+// newParquetDecimal returns a decimal column, its unscaled values being integers or big endian byte arrays
+func newParquetDecimal(precision int32, scale int32) parquetColumn {
+	return parquetColumn{
+		dataType: DecimalDataType(int(precision), int(scale)),
+		format: func(value interface{}) string {
+			unscaled := new(big.Int)
+			switch value := value.(type) {
+			case int32:
+				unscaled.SetInt64(int64(value))
+			case int64:
+				unscaled.SetInt64(value)
+			case string:
+				unscaled.SetBytes([]byte(value))
+				// two's complement
+				if len(value) > 0 && value[0]&0x80 != 0 {
+					unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(value)*8)))
+				}
+			}
+
+			return formatDecimal(unscaled, int(scale))
+		},
+	}
+}
+
+// formatDecimal formats an unscaled decimal value
+func formatDecimal(unscaled *big.Int, scale int) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+
+	if unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+
+	return digits
+}
+
+// formatParquetInteger formats integer values, reinterpreting the unsigned ones
+func formatParquetInteger(signed bool) func(value interface{}) string {
+	return func(value interface{}) string {
+		switch value := value.(type) {
+		case int32:
+			if !signed {
+				return strconv.FormatUint(uint64(uint32(value)), 10)
+			}
+			return strconv.FormatInt(int64(value), 10)
+		case int64:
+			if !signed {
+				return strconv.FormatUint(uint64(value), 10)
+			}
+			return strconv.FormatInt(value, 10)
+		}
+
+		return fmt.Sprint(value)
+	}
+}
+
+// parquetTime formats the time of day values, in milliseconds, microseconds or nanoseconds
+func parquetTime(unit *parquet.TimeUnit) func(value interface{}) string {
+	return func(value interface{}) string {
+		return parquetUnixTime(unit, value).Format("15:04:05.999999")
+	}
+}
+
+// parquetTimestamp formats the timestamps, in milliseconds, microseconds or nanoseconds since the epoch
+func parquetTimestamp(unit *parquet.TimeUnit) func(value interface{}) string {
+	return func(value interface{}) string {
+		return parquetUnixTime(unit, value).Format("2006-01-02 15:04:05.999999")
+	}
+}
+
+// parquetUnixTime converts a time value, since the epoch, to an UTC time. The time is not computed as a
+// duration, that overflows after 2262.
+func parquetUnixTime(unit *parquet.TimeUnit, value interface{}) time.Time {
+	var n int64
+	switch value := value.(type) {
+	case int32:
+		n = int64(value)
+	case int64:
+		n = value
+	}
+
+	switch {
+	case unit.IsSetMILLIS():
+		return time.UnixMilli(n).UTC()
+	case unit.IsSetMICROS():
+		return time.UnixMicro(n).UTC()
+	}
+
+	return time.Unix(0, n).UTC()
+}
+
+// formatParquetDate formats a date, in days since the epoch
+func formatParquetDate(value interface{}) string {
+	days, _ := value.(int32)
+	return time.Unix(0, 0).UTC().AddDate(0, 0, int(days)).Format("2006-01-02")
+}
+
+// formatParquetInt96 formats a legacy timestamp
+func formatParquetInt96(value interface{}) string {
+	s, _ := value.(string)
+	if len(s) != 12 {
+		return ""
+	}
+
+	return types.INT96ToTime(s).UTC().Format("2006-01-02 15:04:05.999999")
+}
+
+// formatParquetUUID formats a 16 bytes UUID
+func formatParquetUUID(value interface{}) string {
+	s, _ := value.(string)
+	if len(s) != 16 {
+		return hex.EncodeToString([]byte(s))
+	}
+
+	b := hex.EncodeToString([]byte(s))
+	return b[:8] + "-" + b[8:12] + "-" + b[12:16] + "-" + b[16:20] + "-" + b[20:]
+}
+
+// formatParquetBool formats a boolean as 1 or 0
+func formatParquetBool(value interface{}) string {
+	if b, _ := value.(bool); b {
+		return "1"
+	}
+
+	return "0"
+}
+
+// formatParquetFloat formats a float without exponent
+func formatParquetFloat(value interface{}) string {
+	switch value := value.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// formatParquetString returns the text, or the bytes, of a value
+func formatParquetString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,153 @@
+package csv2table
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/types"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type parquetTestAddress struct {
+	City string `parquet:"name=City, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type parquetTestRow struct {
+	ID      int32              `parquet:"name=id, type=INT32"`
+	Name    *string            `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Amount  int64              `parquet:"name=amount, type=INT64, convertedtype=DECIMAL, scale=2, precision=10"`
+	Day     int32              `parquet:"name=day, type=INT32, convertedtype=DATE"`
+	Updated int64              `parquet:"name=updated, type=INT64, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MICROS"`
+	Count   int32              `parquet:"name=count, type=INT32, convertedtype=UINT_32"`
+	Active  bool               `parquet:"name=active, type=BOOLEAN"`
+	Score   float64            `parquet:"name=score, type=DOUBLE"`
+	Address parquetTestAddress `parquet:"name=Address"`
+}
+
+type parquetTestRepeated struct {
+	Tags []string `parquet:"name=tags, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REPEATED"`
+}
+
+// writeParquet writes rows to a parquet file, each group of rows in its own row group
+func writeParquet(t *testing.T, obj interface{}, groups ...[]interface{}) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	pw, err := writer.NewParquetWriterFromWriter(buf, obj, 1)
+	assert.Nil(t, err)
+
+	for _, rows := range groups {
+		for _, row := range rows {
+			assert.Nil(t, pw.Write(row))
+		}
+		assert.Nil(t, pw.Flush(true))
+	}
+	assert.Nil(t, pw.WriteStop())
+
+	return buf
+}
+
+func TestParquetReader(t *testing.T) {
+	name := "Ana"
+	updated := time.Date(2026, 10, 15, 13, 45, 0, 500000000, time.UTC)
+	data := writeParquet(t, new(parquetTestRow),
+		[]interface{}{
+			parquetTestRow{ID: 1, Name: &name, Amount: 1205, Day: 20741, Updated: updated.UnixNano() / 1000,
+				Count: 7, Active: true, Score: 0.5, Address: parquetTestAddress{City: "Cluj"}},
+		},
+		[]interface{}{
+			parquetTestRow{ID: 2, Amount: -5, Count: -1},
+			parquetTestRow{ID: 3},
+		},
+	)
+
+	p, err := newParquetReader(data)
+	assert.Nil(t, err)
+	defer p.Close()
+
+	assert.Equal(t, p.DataTypes(), map[string]string{
+		"id":           DataTypeInt,
+		"name":         DataTypeString,
+		"amount":       "decimal(10,2)",
+		"day":          DataTypeDate,
+		"updated":      DataTypeDateTime,
+		"count":        DataTypeBigInt,
+		"active":       DataTypeBool,
+		"score":        DataTypeDouble,
+		"address_city": DataTypeString,
+	})
+
+	assert.Equal(t, readAll(t, p), [][]string{
+		{"id", "name", "amount", "day", "updated", "count", "active", "score", "address_city"},
+		{"1", "Ana", "12.05", "2026-10-15", "2026-10-15 13:45:00.5", "7", "1", "0.5", "Cluj"},
+		{"2", "", "-0.05", "1970-01-01", "1970-01-01 00:00:00", "4294967295", "0", "0", ""},
+		{"3", "", "0.00", "1970-01-01", "1970-01-01 00:00:00", "0", "0", "0", ""},
+	})
+}
+
+func TestParquetReaderRepeated(t *testing.T) {
+	data := writeParquet(t, new(parquetTestRepeated), []interface{}{parquetTestRepeated{Tags: []string{"a"}}})
+
+	_, err := newParquetReader(data)
+	assert.NotNil(t, err)
+
+	_, err = newParquetReader(bytes.NewReader([]byte("id;name\n")))
+	assert.NotNil(t, err)
+}
+
+func TestParquetDecimal(t *testing.T) {
+	column := newParquetDecimal(20, 3)
+	assert.Equal(t, column.dataType, "decimal(20,3)")
+	assert.Equal(t, column.format(int32(-1234)), "-1.234")
+	assert.Equal(t, column.format(int64(7)), "0.007")
+
+	// big endian two's complement
+	assert.Equal(t, column.format(string([]byte{0x30, 0x39})), "12.345")
+	assert.Equal(t, column.format(string([]byte{0xff, 0x85})), "-0.123")
+}
+
+func TestParquetTimestamp(t *testing.T) {
+	millis := parquetTimestamp(&parquet.TimeUnit{MILLIS: parquet.NewMilliSeconds()})
+	micros := parquetTimestamp(&parquet.TimeUnit{MICROS: parquet.NewMicroSeconds()})
+	nanos := parquetTimestamp(&parquet.TimeUnit{NANOS: parquet.NewNanoSeconds()})
+
+	ts := time.Date(2026, 10, 15, 13, 45, 1, 250000000, time.UTC)
+	assert.Equal(t, millis(ts.UnixMilli()), "2026-10-15 13:45:01.25")
+	assert.Equal(t, micros(ts.UnixMicro()), "2026-10-15 13:45:01.25")
+	assert.Equal(t, nanos(ts.UnixNano()), "2026-10-15 13:45:01.25")
+
+	// after the largest duration, in 2262
+	end := time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	assert.Equal(t, millis(end.UnixMilli()), "9999-12-31 23:59:59")
+	assert.Equal(t, micros(end.UnixMicro()), "9999-12-31 23:59:59")
+	assert.Equal(t, formatParquetDate(int32(end.Unix()/86400)), "9999-12-31")
+
+	before := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, micros(before.UnixMicro()), "1900-01-01 00:00:00")
+
+	assert.Equal(t, parquetTime(&parquet.TimeUnit{MILLIS: parquet.NewMilliSeconds()})(int32(49501250)), "13:45:01.25")
+}
+
+func TestParquetInt96(t *testing.T) {
+	ts := time.Date(2026, 10, 15, 13, 45, 1, 0, time.UTC)
+	assert.Equal(t, formatParquetInt96(types.TimeToINT96(ts)), "2026-10-15 13:45:01")
+	assert.Equal(t, inputFormat("export.parquet", viper.New()), FormatParquet)
+}
+
+func TestImportReaderParquet(t *testing.T) {
+	service := &recordService{}
+	v := viper.New()
+	v.Set("format", FormatParquet)
+
+	data := writeParquet(t, new(parquetTestAddress), []interface{}{parquetTestAddress{City: "Cluj"}})
+	status, err := ImportReader(context.Background(), service, data, "cities", v)
+	assert.Nil(t, err)
+	assert.Equal(t, status.RowCount, 1)
+	assert.Equal(t, service.header, []string{"city"})
+
+	// the data types are passed to the service
+	assert.Equal(t, v.GetStringMapString("dataTypes"), map[string]string{"city": DataTypeString})
+}
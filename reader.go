@@ -0,0 +1,167 @@
+package csv2table
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ImportReader imports the csv data read from r into table, running the full DbService lifecycle.
+// The format option of v can select another format, e.g. xlsx.
+// v is the configuration, as merged from the configuration files, it may be nil. If table is not empty
+// it overrides the table of v. The import stops as soon as ctx is cancelled, if service is a DbServiceContext.
+// The returned status holds the row count, size and checksum of the data, and the import result.
+func ImportReader(ctx context.Context, service DbService, r io.Reader, table string, v *viper.Viper) (ImportFileStatus, error) {
+	if v == nil {
+		v = viper.New()
+	}
+	if table != "" {
+		v.Set("table", table)
+	}
+
+	// the name is used for logs only, the table being set
+	name := v.GetString("table")
+	if name == "" {
+		return ImportFileStatus{}, errors.New("table is required to import csv data from a reader")
+	}
+
+	status := ImportFileStatus{
+		FileName: name,
+		Started:  time.Now(),
+	}
+
+	err := importReader(ctx, withContext(service), name, r, v, &status)
+	status.Finished = time.Now()
+	status.SetError(err)
+
+	return status, err
+}
+
+// importReader reads the records of a data file, csv by default, and processes them with service.
+// The row count, size and checksum of the data are set in status.
+func importReader(ctx context.Context, service DbServiceContext, name string, r io.Reader, v *viper.Viper,
+	status *ImportFileStatus) error {
+	// size and checksum are calculated while reading
+	hash := sha256.New()
+	size := &byteCounter{}
+	rr, err := newRecordReader(inputFormat(name, v), io.TeeReader(r, io.MultiWriter(hash, size)), v)
+	if err != nil {
+		return err
+	}
+	if c, ok := rr.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	// typed formats set the default column types
+	if t, ok := rr.(typedReader); ok {
+		v.Set("dataTypes", t.DataTypes())
+	}
+
+	// initialize service
+	err = service.StartContext(ctx, name, v)
+	if err != nil {
+		return err
+	}
+	defer service.EndContext(ctx)
+
+	// first line is always the header
+	header, err := rr.Read()
+	if err != nil {
+		return err
+	}
+
+	err = service.ProcessHeaderContext(ctx, header)
+	if err != nil {
+		return err
+	}
+
+	for {
+		line, err := rr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		status.RowCount++
+
+		// rest of the lines are content
+		err = service.ProcessLineContext(ctx, line)
+		if err != nil {
+			return err
+		}
+	}
+
+	status.Checksum = hex.EncodeToString(hash.Sum(nil))
+	status.Size = size.count
+
+	// signal end of csv data
+	return service.EndContext(ctx)
+}
+
+// withContext returns service as a DbServiceContext. Services not supporting cancellation
+// are wrapped, checking ctx before each call.
+func withContext(service DbService) DbServiceContext {
+	if s, ok := service.(DbServiceContext); ok {
+		return s
+	}
+
+	return &contextService{DbService: service}
+}
+
+// contextService adds the context methods to a DbService
+type contextService struct {
+	DbService
+
+	ended bool
+}
+
+func (s *contextService) StartContext(ctx context.Context, fileName string, v *viper.Viper) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Start(fileName, v)
+}
+
+// EndContext calls End once, like DbServiceContext services End can be called more than once
+func (s *contextService) EndContext(ctx context.Context) error {
+	if s.ended {
+		return nil
+	}
+	s.ended = true
+
+	return s.End()
+}
+
+func (s *contextService) ProcessHeaderContext(ctx context.Context, header []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.ProcessHeader(header)
+}
+
+func (s *contextService) ProcessLineContext(ctx context.Context, line []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.ProcessLine(line)
+}
+
+// byteCounter is an io.Writer counting the bytes written to it
+type byteCounter struct {
+	count int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.count += int64(len(p))
+	return len(p), nil
+}
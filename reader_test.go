@@ -0,0 +1,108 @@
+package csv2table
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordService is a DbService recording the calls it receives
+type recordService struct {
+	table  string
+	header []string
+	lines  [][]string
+	ends   int
+}
+
+func (s *recordService) Start(fileName string, v *viper.Viper) error {
+	s.table = v.GetString("table")
+	return nil
+}
+
+func (s *recordService) End() error {
+	s.ends++
+	return nil
+}
+
+func (s *recordService) ProcessHeader(header []string) error {
+	s.header = header
+	return nil
+}
+
+func (s *recordService) ProcessLine(line []string) error {
+	s.lines = append(s.lines, line)
+	return nil
+}
+
+func TestImportReader(t *testing.T) {
+	service := &recordService{}
+	data := "id;name\n1;a\n2;b\n"
+
+	status, err := ImportReader(context.Background(), service, strings.NewReader(data), "people", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, service.table, "people")
+	assert.Equal(t, service.header, []string{"id", "name"})
+	assert.Equal(t, service.lines, [][]string{{"1", "a"}, {"2", "b"}})
+	assert.Equal(t, service.ends, 1)
+
+	assert.Equal(t, status.Status, StatusImported)
+	assert.Equal(t, status.RowCount, 2)
+	assert.Equal(t, status.Size, int64(len(data)))
+	assert.Equal(t, len(status.Checksum), 64)
+}
+
+func TestImportReaderConfigTable(t *testing.T) {
+	service := &recordService{}
+	v := viper.New()
+	v.Set("table", "configured")
+
+	_, err := ImportReader(context.Background(), service, strings.NewReader("id\n1\n"), "", v)
+	assert.Nil(t, err)
+	assert.Equal(t, service.table, "configured")
+
+	// a table is required
+	_, err = ImportReader(context.Background(), service, strings.NewReader("id\n1\n"), "", nil)
+	assert.NotNil(t, err)
+}
+
+func TestImportReaderCancelled(t *testing.T) {
+	service := &recordService{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	status, err := ImportReader(ctx, service, strings.NewReader("id\n1\n"), "people", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, status.Status, StatusCancelled)
+	assert.Equal(t, len(service.lines), 0)
+}
+
+func TestImportReaderSkipRows(t *testing.T) {
+	service := &recordService{}
+	v := viper.New()
+	v.Set("skipRows", 2)
+
+	// the skipped lines are not csv records
+	data := "Monthly report\n\"unbalanced\nid;name\n1;a\n"
+	_, err := ImportReader(context.Background(), service, strings.NewReader(data), "people", v)
+	assert.Nil(t, err)
+	assert.Equal(t, service.header, []string{"id", "name"})
+	assert.Equal(t, service.lines, [][]string{{"1", "a"}})
+}
+
+func TestImportReaderDelimiterEncoding(t *testing.T) {
+	service := &recordService{}
+	v := viper.New()
+	v.Set("delimiter", ",")
+	v.Set("encoding", "windows-1252")
+
+	_, err := ImportReader(context.Background(), service, strings.NewReader("id,name\n1,B\xe4cker\n"), "people", v)
+	assert.Nil(t, err)
+	assert.Equal(t, service.lines, [][]string{{"1", "Bäcker"}})
+
+	v.Set("encoding", "klingon")
+	_, err = ImportReader(context.Background(), service, strings.NewReader("id\n1\n"), "people", v)
+	assert.NotNil(t, err)
+}
@@ -1,9 +1,15 @@
 package csv2table
 
-import "strings"
+import (
+	"strings"
+	"sync"
+)
 
 // dbNameReplacer is a singleton instance of NewReplacer used to sanitize column names
-var dbNameReplacer *strings.Replacer
+var (
+	dbNameReplacer     *strings.Replacer
+	dbNameReplacerOnce sync.Once
+)
 
 // SanitizeName converts a string to a form that can be used as a db name,
 // such as a table name or a column name
@@ -28,7 +34,7 @@ func SanitizeNames(names []string) []string {
 
 // replacer provides a strings.Replacer for db names sanitization
 func replacer() *strings.Replacer {
-	if dbNameReplacer == nil {
+	dbNameReplacerOnce.Do(func() {
 		chars := []string{
 			// remove "commonly" used characters that could appear in csv header
 			// that are not usable as a db name
@@ -48,7 +54,7 @@ func replacer() *strings.Replacer {
 		chars = append(chars, umlautPairs...)
 
 		dbNameReplacer = strings.NewReplacer(chars...)
-	}
+	})
 
 	return dbNameReplacer
 }
@@ -0,0 +1,141 @@
+package csv2table
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FilePattern maps the files matching a pattern to a shared configuration file and table,
+// e.g. dated files such as sales_20261015.csv
+type FilePattern struct {
+	Pattern string // glob matched against the csv name, e.g. sales_*.csv
+	Config  string // configuration file, relative to the scanned directory
+	Table   string // target table, optional
+}
+
+// ScanDir finds the inputs of a directory, in lexical order. Subdirectories are scanned
+// only if Config.Recursive is set, skipping hidden and archive directories.
+// The inputs are filtered by the includeFiles and exclude patterns, and the file patterns are applied.
+// The inputs inherit the directory configuration files found from dir down to their directory.
+// A file or subdirectory that can't be read, e.g. a corrupt zip archive, is logged and skipped,
+// only an unreadable dir fails the scan.
+func ScanDir(dir string, config Config) ([]Input, error) {
+	return scanDir(dir, config, log.Default())
+}
+
+// scanDir is ScanDir, logging the skipped files with logger
+func scanDir(dir string, config Config, logger Logger) ([]Input, error) {
+	// never import archived files again
+	skipDirs := make(map[string]bool)
+	for _, archiveDir := range []string{config.ArchiveDir, config.ErrorDir} {
+		if archiveDir == "" {
+			continue
+		}
+		if root, err := archiveRoot(archiveDir); err == nil {
+			skipDirs[filepath.Clean(filepath.Join(dir, root))] = true
+		}
+	}
+
+	var inputs []Input
+	configs := make(map[string]bool)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return skipWalkError(dir, path, info, err, logger)
+		}
+
+		if info.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if !config.Recursive || strings.HasPrefix(info.Name(), ".") || skipDirs[filepath.Clean(path)] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !IsInputFile(info.Name()) || !config.selected(rel) {
+			return nil
+		}
+
+		fileInputs, err := FileInputs(path)
+		if err != nil {
+			logger.Printf("unable to read %s, %v", path, err)
+			return nil
+		}
+
+		inherited := dirConfigs(dir, filepath.Dir(path), configs)
+		for _, in := range fileInputs {
+			in.DirConfigs = inherited
+			if pattern, found := config.filePattern(in.Name); found {
+				in.Config = filepath.Join(dir, pattern.Config)
+				in.Table = pattern.Table
+			}
+			inputs = append(inputs, in)
+		}
+
+		return nil
+	})
+
+	return inputs, err
+}
+
+// skipWalkError handles an error of the directory walk: an unreadable subdirectory is skipped, like a file
+// removed during the walk. Only the errors of the scanned directory itself are returned.
+func skipWalkError(dir string, path string, info os.FileInfo, err error, logger Logger) error {
+	if path == dir {
+		return err
+	}
+
+	logger.Printf("unable to read %s, %v", path, err)
+	if info != nil && info.IsDir() {
+		return filepath.SkipDir
+	}
+
+	return nil
+}
+
+// selected checks a file path, relative to the scanned directory, against the includeFiles and exclude patterns
+func (c Config) selected(rel string) bool {
+	if len(c.IncludeFiles) > 0 && !matchAny(c.IncludeFiles, rel) {
+		return false
+	}
+
+	return !matchAny(c.Exclude, rel)
+}
+
+// filePattern returns the first file pattern matching a csv name
+func (c Config) filePattern(name string) (FilePattern, bool) {
+	for _, pattern := range c.Files {
+		if matchAny([]string{pattern.Pattern}, name) || matchAny([]string{pattern.Pattern}, trimCompression(name)) {
+			return pattern, true
+		}
+	}
+
+	return FilePattern{}, false
+}
+
+// matchAny checks whether a slash separated path matches any of the glob patterns.
+// Patterns containing a slash are matched against the whole path, the others against the file name only.
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package csv2table
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csv2table")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"sales_20261015.csv", "orders.csv", "orders_tmp.csv", "notes.txt",
+		"incoming/sales_20261016.csv.gz", "archive/2026-10-14/sales_20261014.csv", ".hidden/x.csv"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, nil, 0644)
+	}
+
+	config := NewConfig()
+	config.Exclude = []string{"*_tmp.csv"}
+	config.ArchiveDir = "archive/{date}"
+	config.Files = []FilePattern{{Pattern: "sales_*.csv", Config: "sales.toml", Table: "sales"}}
+
+	inputs, err := ScanDir(dir, config)
+	if assert.Nil(t, err) && assert.Len(t, inputs, 2) {
+		assert.Equal(t, inputs[0].Name, "orders.csv")
+		assert.Equal(t, inputs[0].ConfigFileName(), filepath.Join(dir, "orders.toml"))
+		assert.Equal(t, inputs[1].Name, "sales_20261015.csv")
+		assert.Equal(t, inputs[1].ConfigFileName(), filepath.Join(dir, "sales.toml"))
+		assert.Equal(t, inputs[1].Table, "sales")
+	}
+
+	// recursive, skipping hidden and archive directories
+	config.Recursive = true
+	inputs, err = ScanDir(dir, config)
+	if assert.Nil(t, err) && assert.Len(t, inputs, 3) {
+		assert.Equal(t, inputs[0].Path, filepath.Join(dir, "incoming", "sales_20261016.csv.gz"))
+		assert.Equal(t, inputs[0].Table, "sales")
+	}
+
+	// include
+	config.IncludeFiles = []string{"incoming/*"}
+	inputs, err = ScanDir(dir, config)
+	if assert.Nil(t, err) {
+		assert.Len(t, inputs, 1)
+	}
+}
+
+func TestScanDirCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "batch.zip"), []byte("truncated"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "orders.csv"), nil, 0644)
+
+	var buf bytes.Buffer
+	inputs, err := scanDir(dir, NewConfig(), log.New(&buf, "", 0))
+	assert.Nil(t, err)
+	if assert.Len(t, inputs, 1) {
+		assert.Equal(t, inputs[0].Name, "orders.csv")
+	}
+	assert.Contains(t, buf.String(), "unable to read "+filepath.Join(dir, "batch.zip"))
+}
+
+func TestScanDirUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	_, err := scanDir(filepath.Join(dir, "missing"), NewConfig(), logger)
+	assert.NotNil(t, err)
+
+	// an unreadable subdirectory is skipped, a file removed during the walk is ignored
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	info, err := os.Stat(sub)
+	assert.Nil(t, err)
+	assert.Equal(t, skipWalkError(dir, sub, info, os.ErrPermission, logger), filepath.SkipDir)
+	assert.Nil(t, skipWalkError(dir, filepath.Join(dir, "gone.csv"), nil, os.ErrNotExist, logger))
+	assert.Equal(t, skipWalkError(dir, dir, info, os.ErrPermission, logger), os.ErrPermission)
+	assert.Contains(t, buf.String(), "unable to read "+sub)
+
+	// without permissions, for users other than root
+	if os.Geteuid() != 0 {
+		config := NewConfig()
+		config.Recursive = true
+		ioutil.WriteFile(filepath.Join(dir, "orders.csv"), nil, 0644)
+		assert.Nil(t, os.Chmod(sub, 0))
+		defer os.Chmod(sub, 0755)
+
+		inputs, err := scanDir(dir, config, logger)
+		assert.Nil(t, err)
+		assert.Len(t, inputs, 1)
+	}
+}
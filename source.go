@@ -0,0 +1,147 @@
+package csv2table
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// what to do with a remote file after its successful import
+const (
+	AfterDelete = "delete"
+	AfterMove   = "move"
+)
+
+// Source is the interface implemented by the locations csv files are fetched from before being imported,
+// e.g. an SFTP server. File names are relative to the source directory.
+type Source interface {
+	// List returns the names of the files of the source directory
+	List(ctx context.Context) ([]string, error)
+
+	// Fetch copies the content of a file to w
+	Fetch(ctx context.Context, name string, w io.Writer) error
+
+	// Remove deletes a file
+	Remove(ctx context.Context, name string) error
+
+	// Move moves a file to another directory of the source
+	Move(ctx context.Context, name string, dir string) error
+
+	// Close releases the connection to the source
+	Close() error
+}
+
+// SourceConfig holds the configuration of a source, read from the sources section of the global configuration file
+type SourceConfig struct {
+	Type string // sftp, ftp, ftps, http or local
+
+	Host       string // host[:port] of sftp and ftp(s) servers
+	Username   string
+	Password   string
+	KeyFile    string // private key file for sftp
+	KnownHosts string // known_hosts file used to verify sftp servers, ~/.ssh/known_hosts if empty
+	Insecure   bool   // don't verify the server (sftp host key, ftps certificate)
+
+	Dir  string   // remote directory, or local directory for the local source
+	URLs []string // files to download for the http source
+
+	Include []string // glob patterns of the files to fetch, all csv files if empty
+	After   string   // what to do with the remote file after a successful import: delete, move or nothing
+	MoveDir string   // remote directory the files are moved to, when After is move
+	WorkDir string   // local directory the files are downloaded to, the working directory if empty
+}
+
+// FetchedFile is a file downloaded from a source
+type FetchedFile struct {
+	Name string // name in the source directory
+	Path string // downloaded file
+}
+
+// FetchFiles downloads the matching files of a source into SourceConfig.WorkDir.
+// Each file is written to a temporary file first, so that a partial download is never imported.
+func FetchFiles(ctx context.Context, src Source, config SourceConfig) ([]FetchedFile, error) {
+	names, err := src.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workDir := config.WorkDir
+	if workDir == "" {
+		workDir = "."
+	}
+	err = os.MkdirAll(workDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FetchedFile
+	for _, name := range names {
+		if !IsInputFile(name) || (len(config.Include) > 0 && !matchAny(config.Include, name)) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return files, err
+		}
+
+		path := filepath.Join(workDir, filepath.Base(name))
+		err = fetchFile(ctx, src, name, path)
+		if err != nil {
+			return files, fmt.Errorf("unable to fetch %s, %v", name, err)
+		}
+
+		files = append(files, FetchedFile{Name: name, Path: path})
+	}
+
+	return files, nil
+}
+
+// FinishFetched deletes or moves a fetched file from its source, as set by SourceConfig.After.
+// It should be called only after the file was successfully imported.
+func FinishFetched(ctx context.Context, src Source, config SourceConfig, file FetchedFile) error {
+	switch config.After {
+	case AfterDelete:
+		return src.Remove(ctx, file.Name)
+	case AfterMove:
+		return src.Move(ctx, file.Name, config.MoveDir)
+	}
+
+	return nil
+}
+
+// ValidateSourceConfig checks the options common to all sources
+func ValidateSourceConfig(config SourceConfig) error {
+	switch config.After {
+	case "", AfterDelete:
+	case AfterMove:
+		if config.MoveDir == "" {
+			return fmt.Errorf("moveDir is required to move the files of a %s source", config.Type)
+		}
+	default:
+		return fmt.Errorf("invalid after option %s, expecting %s or %s", config.After, AfterDelete, AfterMove)
+	}
+
+	return nil
+}
+
+// fetchFile downloads a file to a hidden temporary file, renamed to path once complete
+func fetchFile(ctx context.Context, src Source, name string, path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = src.Fetch(ctx, name, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,138 @@
+package source
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+
+	"github.com/schiorean/csv2table"
+)
+
+const defaultFtpPort = "21"
+
+// ftpSource fetches files from an FTP server, using explicit TLS for ftps
+type ftpSource struct {
+	dir  string
+	conn *ftp.ServerConn
+}
+
+// newFtp connects to an FTP server and logs in
+func newFtp(config csv2table.SourceConfig) (*ftpSource, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("host is required for a %s source", config.Type)
+	}
+
+	addr := hostPort(config.Host, defaultFtpPort)
+	options := []ftp.DialOption{ftp.DialWithTimeout(defaultDialTimeout)}
+	if config.Type == TypeFtps {
+		host, _, _ := net.SplitHostPort(addr)
+		options = append(options, ftp.DialWithExplicitTLS(&tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: config.Insecure,
+		}))
+	}
+
+	conn, err := ftp.Dial(addr, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.Login(config.Username, config.Password)
+	if err != nil {
+		conn.Quit()
+		return nil, err
+	}
+
+	return &ftpSource{dir: config.Dir, conn: conn}, nil
+}
+
+// List returns the regular files of the remote directory
+func (f *ftpSource) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := f.conn.List(f.remotePath(""))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type == ftp.EntryTypeFile {
+			names = append(names, path.Base(entry.Name))
+		}
+	}
+
+	return names, nil
+}
+
+// Fetch downloads a file to w. When ctx is cancelled the download is interrupted.
+func (f *ftpSource) Fetch(ctx context.Context, name string, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r, err := f.conn.Retr(f.remotePath(name))
+	if err != nil {
+		return err
+	}
+
+	// the deadline stops the pending read of the data connection
+	stop := context.AfterFunc(ctx, func() { r.SetDeadline(time.Now()) })
+	defer stop()
+
+	_, err = io.Copy(w, r)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// Remove deletes a remote file
+func (f *ftpSource) Remove(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return f.conn.Delete(f.remotePath(name))
+}
+
+// Move moves a remote file to dir, relative to the source directory unless absolute.
+// The directory must exist.
+func (f *ftpSource) Move(ctx context.Context, name string, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !path.IsAbs(dir) {
+		dir = f.remotePath(dir)
+	}
+
+	return f.conn.Rename(f.remotePath(name), path.Join(dir, name))
+}
+
+// Close logs out
+func (f *ftpSource) Close() error {
+	return f.conn.Quit()
+}
+
+// remotePath returns the path of a file of the source directory
+func (f *ftpSource) remotePath(name string) string {
+	dir := f.dir
+	if dir == "" {
+		dir = "."
+	}
+
+	return path.Join(dir, name)
+}
@@ -0,0 +1,221 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/schiorean/csv2table"
+)
+
+// startFtpServer starts an in-process FTP server serving dir, with the few commands used by ftpSource.
+// If block is set, each download sends the file content, then waits for the client to close
+// the data connection, e.g. to test the cancellation.
+func startFtpServer(t *testing.T, dir string, block bool) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFtp(conn, dir, block)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// serveFtp serves the control connection of an FTP client
+func serveFtp(conn net.Conn, dir string, block bool) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var data net.Listener
+	var renameFrom string
+	reply("220 ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg := strings.TrimSpace(line), ""
+		if i := strings.Index(cmd, " "); i >= 0 {
+			cmd, arg = cmd[:i], cmd[i+1:]
+		}
+		local := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+arg)))
+
+		switch cmd {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			if arg == "secret" {
+				reply("230 logged in")
+			} else {
+				reply("530 login incorrect")
+			}
+		case "FEAT":
+			reply("211 no features")
+		case "TYPE":
+			reply("200 type set")
+		case "EPSV":
+			data, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				return
+			}
+			reply("229 entering extended passive mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "LIST", "RETR":
+			dc, err := data.Accept()
+			data.Close()
+			if err != nil {
+				return
+			}
+			reply("150 opening data connection")
+			if cmd == "LIST" {
+				infos, _ := ioutil.ReadDir(local)
+				for _, info := range infos {
+					kind := "-"
+					if info.IsDir() {
+						kind = "d"
+					}
+					fmt.Fprintf(dc, "%srw-r--r-- 1 owner group %d Oct 15 03:00 %s\r\n", kind, info.Size(), info.Name())
+				}
+			} else {
+				content, _ := ioutil.ReadFile(local)
+				dc.Write(content)
+				if block {
+					// wait for the client to close the connection
+					ioutil.ReadAll(dc)
+				}
+			}
+			dc.Close()
+			reply("226 transfer complete")
+		case "DELE":
+			if os.Remove(local) != nil {
+				reply("550 not found")
+			} else {
+				reply("250 deleted")
+			}
+		case "RNFR":
+			renameFrom = local
+			reply("350 ready for destination")
+		case "RNTO":
+			if os.Rename(renameFrom, local) != nil {
+				reply("550 rename failed")
+			} else {
+				reply("250 renamed")
+			}
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestFtpSource(t *testing.T) {
+	remote := t.TempDir()
+	os.MkdirAll(filepath.Join(remote, "out", "done"), 0755)
+	ioutil.WriteFile(filepath.Join(remote, "out", "a.csv"), []byte("a;b\n1;2\n"), 0644)
+	ioutil.WriteFile(filepath.Join(remote, "out", "b.csv"), []byte("a;b\n3;4\n"), 0644)
+	ioutil.WriteFile(filepath.Join(remote, "out", "readme.txt"), []byte("not a csv"), 0644)
+
+	config := csv2table.SourceConfig{
+		Type:     TypeFtp,
+		Host:     startFtpServer(t, remote, false),
+		Username: "user",
+		Password: "secret",
+		Dir:      "out",
+		After:    csv2table.AfterMove,
+		MoveDir:  "done",
+		WorkDir:  t.TempDir(),
+	}
+
+	src, err := New(config)
+	assert.Nil(t, err)
+	defer src.Close()
+
+	ctx := context.Background()
+	files, err := csv2table.FetchFiles(ctx, src, config)
+	assert.Nil(t, err)
+	assert.Equal(t, len(files), 2)
+
+	data, err := ioutil.ReadFile(filepath.Join(config.WorkDir, "b.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), "a;b\n3;4\n")
+
+	// only the finished file is moved
+	err = csv2table.FinishFetched(ctx, src, config, files[0])
+	assert.Nil(t, err)
+	assert.FileExists(t, filepath.Join(remote, "out", "done", "a.csv"))
+	assert.FileExists(t, filepath.Join(remote, "out", "b.csv"))
+
+	config.After = csv2table.AfterDelete
+	err = csv2table.FinishFetched(ctx, src, config, files[1])
+	assert.Nil(t, err)
+	assert.NoFileExists(t, filepath.Join(remote, "out", "b.csv"))
+}
+
+func TestFtpSourceCancel(t *testing.T) {
+	remote := t.TempDir()
+	ioutil.WriteFile(filepath.Join(remote, "a.csv"), []byte("a;b\n1;2\n"), 0644)
+
+	src, err := New(csv2table.SourceConfig{
+		Type:     TypeFtp,
+		Host:     startFtpServer(t, remote, true),
+		Username: "user",
+		Password: "secret",
+	})
+	assert.Nil(t, err)
+	defer src.Close()
+
+	// the download never ends, until cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancelWriter{cancel: cancel}
+	err = src.Fetch(ctx, "a.csv", w)
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, w.String(), "a;b\n1;2\n")
+
+	_, err = src.List(ctx)
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, src.Remove(ctx, "a.csv"), context.Canceled)
+	assert.FileExists(t, filepath.Join(remote, "a.csv"))
+}
+
+func TestFtpSourceLogin(t *testing.T) {
+	_, err := New(csv2table.SourceConfig{
+		Type:     TypeFtp,
+		Host:     startFtpServer(t, t.TempDir(), false),
+		Username: "user",
+		Password: "wrong",
+	})
+	assert.NotNil(t, err)
+}
+
+// cancelWriter cancels the download once the first data is written
+type cancelWriter struct {
+	strings.Builder
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	defer w.cancel()
+	return w.Builder.Write(p)
+}
@@ -0,0 +1,103 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/schiorean/csv2table"
+)
+
+// httpSource downloads a fixed list of urls. Files can't be removed or moved.
+type httpSource struct {
+	username string
+	password string
+	urls     map[string]string // url by file name
+	names    []string          // file names, in the order of the urls
+	client   *http.Client
+}
+
+// newHttp creates an http source
+func newHttp(config csv2table.SourceConfig) (*httpSource, error) {
+	if len(config.URLs) == 0 {
+		return nil, fmt.Errorf("urls are required for a %s source", TypeHttp)
+	}
+	if config.After != "" {
+		return nil, fmt.Errorf("files of a %s source can't be removed or moved", TypeHttp)
+	}
+
+	h := &httpSource{
+		username: config.Username,
+		password: config.Password,
+		urls:     make(map[string]string),
+		client:   &http.Client{},
+	}
+
+	for _, rawURL := range config.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Base(u.Path)
+		if _, exists := h.urls[name]; exists {
+			return nil, fmt.Errorf("duplicate file name %s in urls", name)
+		}
+		h.urls[name] = rawURL
+		h.names = append(h.names, name)
+	}
+
+	return h, nil
+}
+
+// List returns the file names of the urls
+func (h *httpSource) List(ctx context.Context) ([]string, error) {
+	return h.names, nil
+}
+
+// Fetch downloads a file to w
+func (h *httpSource) Fetch(ctx context.Context, name string, w io.Writer) error {
+	rawURL, exists := h.urls[name]
+	if !exists {
+		return fmt.Errorf("unknown file %s", name)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	if h.username != "" {
+		req.SetBasicAuth(h.username, h.password)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Remove is not supported
+func (h *httpSource) Remove(ctx context.Context, name string) error {
+	return fmt.Errorf("files of a %s source can't be removed", TypeHttp)
+}
+
+// Move is not supported
+func (h *httpSource) Move(ctx context.Context, name string, dir string) error {
+	return fmt.Errorf("files of a %s source can't be moved", TypeHttp)
+}
+
+// Close does nothing
+func (h *httpSource) Close() error {
+	return nil
+}
@@ -0,0 +1,59 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/schiorean/csv2table"
+)
+
+func TestHttpSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/export/data.csv" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("a;b\n1;2\n"))
+	}))
+	defer server.Close()
+
+	src, err := New(csv2table.SourceConfig{
+		Type:     TypeHttp,
+		Username: "user",
+		Password: "secret",
+		URLs:     []string{server.URL + "/export/data.csv", server.URL + "/missing.csv"},
+	})
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	names, err := src.List(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, names, []string{"data.csv", "missing.csv"})
+
+	var buf bytes.Buffer
+	err = src.Fetch(ctx, "data.csv", &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "a;b\n1;2\n")
+
+	err = src.Fetch(ctx, "missing.csv", &buf)
+	assert.NotNil(t, err)
+}
+
+func TestHttpSourceAfter(t *testing.T) {
+	// remote files can't be deleted over http
+	_, err := New(csv2table.SourceConfig{
+		Type:  TypeHttp,
+		URLs:  []string{"http://localhost/data.csv"},
+		After: csv2table.AfterDelete,
+	})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,79 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/schiorean/csv2table"
+)
+
+// localSource fetches files from a local (or mounted) directory
+type localSource struct {
+	dir string
+}
+
+// newLocal creates a local source
+func newLocal(config csv2table.SourceConfig) (*localSource, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("dir is required for a %s source", TypeLocal)
+	}
+
+	return &localSource{dir: config.Dir}, nil
+}
+
+// List returns the regular files of the directory
+func (l *localSource) List(ctx context.Context) ([]string, error) {
+	infos, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			names = append(names, info.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// Fetch copies a file to w
+func (l *localSource) Fetch(ctx context.Context, name string, w io.Writer) error {
+	f, err := os.Open(filepath.Join(l.dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Remove deletes a file
+func (l *localSource) Remove(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(l.dir, name))
+}
+
+// Move moves a file to dir, relative to the source directory unless absolute
+func (l *localSource) Move(ctx context.Context, name string, dir string) error {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(l.dir, dir)
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filepath.Join(l.dir, name), filepath.Join(dir, name))
+}
+
+// Close does nothing
+func (l *localSource) Close() error {
+	return nil
+}
@@ -0,0 +1,203 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/schiorean/csv2table"
+)
+
+const defaultSftpPort = "22"
+
+// sftpSource fetches files from an SFTP server
+type sftpSource struct {
+	dir    string
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+// newSftp connects to an SFTP server, authenticating with a password and/or a private key
+func newSftp(config csv2table.SourceConfig) (*sftpSource, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("host is required for a %s source", TypeSftp)
+	}
+
+	var auth []ssh.AuthMethod
+	if config.KeyFile != "" {
+		key, err := ioutil.ReadFile(config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse key file %s, %v", config.KeyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+
+	hostKeyCallback, err := sftpHostKeyCallback(config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := ssh.Dial("tcp", hostPort(config.Host, defaultSftpPort), &ssh.ClientConfig{
+		User:            config.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         defaultDialTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &sftpSource{dir: config.Dir, conn: conn, client: client}, nil
+}
+
+// sftpHostKeyCallback verifies the server against the known_hosts file, unless insecure
+func sftpHostKeyCallback(config csv2table.SourceConfig) (ssh.HostKeyCallback, error) {
+	if config.Insecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	file := config.KnownHosts
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known hosts file %s, %v", file, err)
+	}
+
+	return callback, nil
+}
+
+// cancelOnDone closes the connection once ctx is done, the only way to abort a stalled request.
+// The source is unusable afterwards. The returned function stops watching ctx.
+func (s *sftpSource) cancelOnDone(ctx context.Context) func() bool {
+	return context.AfterFunc(ctx, func() { s.conn.Close() })
+}
+
+// List returns the regular files of the remote directory
+func (s *sftpSource) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	infos, err := s.client.ReadDirContext(ctx, s.remotePath(""))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			names = append(names, info.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// Fetch downloads a file to w
+func (s *sftpSource) Fetch(ctx context.Context, name string, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer s.cancelOnDone(ctx)()
+
+	err := s.fetch(name, w)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// fetch downloads a file to w, without cancellation
+func (s *sftpSource) fetch(name string, w io.Writer) error {
+	f, err := s.client.Open(s.remotePath(name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteTo(w)
+	return err
+}
+
+// Remove deletes a remote file
+func (s *sftpSource) Remove(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer s.cancelOnDone(ctx)()
+
+	err := s.client.Remove(s.remotePath(name))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// Move moves a remote file to dir, relative to the source directory unless absolute
+func (s *sftpSource) Move(ctx context.Context, name string, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer s.cancelOnDone(ctx)()
+
+	if !path.IsAbs(dir) {
+		dir = s.remotePath(dir)
+	}
+
+	err := s.client.MkdirAll(dir)
+	if err == nil {
+		err = s.client.Rename(s.remotePath(name), path.Join(dir, name))
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// Close closes the connection
+func (s *sftpSource) Close() error {
+	s.client.Close()
+	return s.conn.Close()
+}
+
+// remotePath returns the path of a file of the source directory
+func (s *sftpSource) remotePath(name string) string {
+	dir := s.dir
+	if dir == "" {
+		dir = "."
+	}
+
+	return path.Join(dir, name)
+}
@@ -0,0 +1,184 @@
+package source
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/schiorean/csv2table"
+)
+
+// startSftpServer starts an in-process SFTP server serving dir, returning its address and host key
+func startSftpServer(t *testing.T, dir string) (string, ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.Nil(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "user" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, assert.AnError
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSftp(conn, config, dir)
+		}
+	}()
+
+	return listener.Addr().String(), signer.PublicKey()
+}
+
+// serveSftp serves the sftp subsystem of an ssh connection
+func serveSftp(conn net.Conn, config *ssh.ServerConfig, dir string) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for req := range requests {
+				// payload is the length prefixed subsystem name
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(dir))
+				if err != nil {
+					channel.Close()
+					return
+				}
+				server.Serve()
+				server.Close()
+			}
+		}()
+	}
+}
+
+// sftpTestConfig starts an SFTP server serving dir and returns the configuration of a source connecting to it
+func sftpTestConfig(t *testing.T, dir string) csv2table.SourceConfig {
+	addr, hostKey := startSftpServer(t, dir)
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0644)
+
+	return csv2table.SourceConfig{
+		Type:       TypeSftp,
+		Host:       addr,
+		Username:   "user",
+		Password:   "secret",
+		KnownHosts: knownHosts,
+	}
+}
+
+func TestSftpSource(t *testing.T) {
+	remote := t.TempDir()
+	os.MkdirAll(filepath.Join(remote, "out"), 0755)
+	ioutil.WriteFile(filepath.Join(remote, "out", "a.csv"), []byte("a;b\n1;2\n"), 0644)
+	ioutil.WriteFile(filepath.Join(remote, "out", "b.csv"), []byte("a;b\n3;4\n"), 0644)
+	ioutil.WriteFile(filepath.Join(remote, "out", "readme.txt"), []byte("not a csv"), 0644)
+
+	config := sftpTestConfig(t, remote)
+	config.Dir = "out"
+	config.After = csv2table.AfterMove
+	config.MoveDir = "done"
+	config.WorkDir = t.TempDir()
+
+	src, err := New(config)
+	assert.Nil(t, err)
+	defer src.Close()
+
+	ctx := context.Background()
+	files, err := csv2table.FetchFiles(ctx, src, config)
+	assert.Nil(t, err)
+	assert.Equal(t, len(files), 2)
+
+	data, err := ioutil.ReadFile(filepath.Join(config.WorkDir, "b.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), "a;b\n3;4\n")
+
+	// only the finished file is moved
+	err = csv2table.FinishFetched(ctx, src, config, files[0])
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(remote, "out", "done", "a.csv"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(remote, "out", "b.csv"))
+	assert.Nil(t, err)
+}
+
+func TestSftpSourceCancel(t *testing.T) {
+	remote := t.TempDir()
+	ioutil.WriteFile(filepath.Join(remote, "a.csv"), make([]byte, 16<<20), 0644)
+
+	src, err := New(sftpTestConfig(t, remote))
+	assert.Nil(t, err)
+	defer src.Close()
+
+	// the download is aborted once cancelled, long before its end
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancelWriter{cancel: cancel}
+	err = src.Fetch(ctx, "a.csv", w)
+	assert.Equal(t, err, context.Canceled)
+	assert.True(t, w.Len() < 16<<20)
+
+	_, err = src.List(ctx)
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, src.Remove(ctx, "a.csv"), context.Canceled)
+	assert.Equal(t, src.Move(ctx, "a.csv", "done"), context.Canceled)
+	assert.FileExists(t, filepath.Join(remote, "a.csv"))
+}
+
+func TestSftpSourceUnknownHost(t *testing.T) {
+	addr, _ := startSftpServer(t, t.TempDir())
+
+	// the server is not in the known hosts file
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	ioutil.WriteFile(knownHosts, nil, 0644)
+
+	_, err := New(csv2table.SourceConfig{
+		Type:       TypeSftp,
+		Host:       addr,
+		Username:   "user",
+		Password:   "secret",
+		KnownHosts: knownHosts,
+	})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,71 @@
+// Package source implements the csv2table.Source interface for SFTP, FTP(S) and HTTP(S) servers
+// and local directories.
+package source
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/schiorean/csv2table"
+)
+
+// source types
+const (
+	TypeSftp  = "sftp"
+	TypeFtp   = "ftp"
+	TypeFtps  = "ftps"
+	TypeHttp  = "http"
+	TypeLocal = "local"
+)
+
+// defaultDialTimeout is the timeout of the connections to the sftp and ftp servers
+const defaultDialTimeout = 30 * time.Second
+
+// New connects to the source described by config
+func New(config csv2table.SourceConfig) (csv2table.Source, error) {
+	err := csv2table.ValidateSourceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// a failed connection returns a nil Source, never a Source holding a nil pointer
+	switch config.Type {
+	case TypeSftp:
+		src, err := newSftp(config)
+		if err != nil {
+			return nil, err
+		}
+		return src, nil
+	case TypeFtp, TypeFtps:
+		src, err := newFtp(config)
+		if err != nil {
+			return nil, err
+		}
+		return src, nil
+	case TypeHttp:
+		src, err := newHttp(config)
+		if err != nil {
+			return nil, err
+		}
+		return src, nil
+	case TypeLocal:
+		src, err := newLocal(config)
+		if err != nil {
+			return nil, err
+		}
+		return src, nil
+	}
+
+	return nil, fmt.Errorf("unknown source type %s, expecting %s, %s, %s, %s or %s",
+		config.Type, TypeSftp, TypeFtp, TypeFtps, TypeHttp, TypeLocal)
+}
+
+// hostPort adds the default port to a host without port
+func hostPort(host string, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,31 @@
+package source
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/schiorean/csv2table"
+)
+
+func TestNewFailed(t *testing.T) {
+	// a port nobody listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	for _, config := range []csv2table.SourceConfig{
+		{Type: TypeSftp, Host: addr, Insecure: true},
+		{Type: TypeFtp, Host: addr},
+		{Type: TypeHttp},
+		{Type: TypeLocal},
+		{Type: "smb"},
+	} {
+		src, err := New(config)
+		assert.NotNil(t, err, config.Type)
+		// not a Source holding a nil pointer, whose Close would panic
+		assert.True(t, src == nil, config.Type)
+	}
+}
@@ -0,0 +1,105 @@
+package csv2table
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memSource is an in-memory Source
+type memSource struct {
+	files   map[string]string
+	names   []string
+	removed []string
+}
+
+func (m *memSource) List(ctx context.Context) ([]string, error) {
+	return m.names, nil
+}
+
+func (m *memSource) Fetch(ctx context.Context, name string, w io.Writer) error {
+	content, exists := m.files[name]
+	if !exists {
+		return fmt.Errorf("%s not found", name)
+	}
+
+	_, err := io.WriteString(w, content)
+	return err
+}
+
+func (m *memSource) Remove(ctx context.Context, name string) error {
+	m.removed = append(m.removed, name)
+	return nil
+}
+
+func (m *memSource) Move(ctx context.Context, name string, dir string) error {
+	return nil
+}
+
+func (m *memSource) Close() error {
+	return nil
+}
+
+func TestFetchFiles(t *testing.T) {
+	src := &memSource{
+		files: map[string]string{"sales.csv": "a\n1\n", "stock.csv.gz": "gz", "notes.txt": "x"},
+		names: []string{"notes.txt", "sales.csv", "stock.csv.gz"},
+	}
+	config := SourceConfig{WorkDir: filepath.Join(t.TempDir(), "in"), After: AfterDelete}
+
+	files, err := FetchFiles(context.Background(), src, config)
+	assert.Nil(t, err)
+	assert.Equal(t, files, []FetchedFile{
+		{Name: "sales.csv", Path: filepath.Join(config.WorkDir, "sales.csv")},
+		{Name: "stock.csv.gz", Path: filepath.Join(config.WorkDir, "stock.csv.gz")},
+	})
+
+	data, err := ioutil.ReadFile(files[0].Path)
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), "a\n1\n")
+
+	// no temporary file is left
+	entries, _ := ioutil.ReadDir(config.WorkDir)
+	assert.Equal(t, len(entries), 2)
+
+	err = FinishFetched(context.Background(), src, config, files[1])
+	assert.Nil(t, err)
+	assert.Equal(t, src.removed, []string{"stock.csv.gz"})
+}
+
+func TestFetchFilesInclude(t *testing.T) {
+	src := &memSource{
+		files: map[string]string{"sales_1.csv": "a", "stock.csv": "b"},
+		names: []string{"sales_1.csv", "stock.csv"},
+	}
+	config := SourceConfig{WorkDir: t.TempDir(), Include: []string{"sales_*.csv"}}
+
+	files, err := FetchFiles(context.Background(), src, config)
+	assert.Nil(t, err)
+	assert.Equal(t, len(files), 1)
+	assert.Equal(t, files[0].Name, "sales_1.csv")
+}
+
+func TestFetchFilesError(t *testing.T) {
+	src := &memSource{names: []string{"missing.csv"}}
+	config := SourceConfig{WorkDir: t.TempDir()}
+
+	_, err := FetchFiles(context.Background(), src, config)
+	assert.NotNil(t, err)
+
+	// the partial download is removed
+	entries, _ := ioutil.ReadDir(config.WorkDir)
+	assert.Equal(t, len(entries), 0)
+}
+
+func TestValidateSourceConfig(t *testing.T) {
+	assert.Nil(t, ValidateSourceConfig(SourceConfig{}))
+	assert.Nil(t, ValidateSourceConfig(SourceConfig{After: AfterMove, MoveDir: "done"}))
+	assert.NotNil(t, ValidateSourceConfig(SourceConfig{After: AfterMove}))
+	assert.NotNil(t, ValidateSourceConfig(SourceConfig{After: "archive"}))
+}
@@ -0,0 +1,137 @@
+package csv2table
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// FileState holds the state of an imported file
+type FileState struct {
+	Checksum string    `json:"checksum"` // checksum of the file content and its configuration
+	Imported time.Time `json:"imported"` // last import time
+}
+
+// StateStore remembers the checksums of the imported files, in a local json file,
+// so that unchanged files can be skipped. It is safe for concurrent use.
+type StateStore struct {
+	fileName string
+
+	mu    sync.Mutex
+	files map[string]FileState
+}
+
+// LoadStateStore loads the state store from fileName. A missing file is an empty store.
+func LoadStateStore(fileName string) (*StateStore, error) {
+	s := &StateStore{
+		fileName: fileName,
+		files:    make(map[string]FileState),
+	}
+
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &s.files)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Unchanged checks whether a file was already imported with the same checksum
+func (s *StateStore) Unchanged(name string, checksum string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.files[name]
+	return exists && state.Checksum == checksum
+}
+
+// Set records a successful import of a file and saves the store
+func (s *StateStore) Set(name string, checksum string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[name] = FileState{
+		Checksum: checksum,
+		Imported: time.Now(),
+	}
+
+	return s.save()
+}
+
+// save writes the store to a temporary file which is then renamed,
+// so the store is never left half written
+func (s *StateStore) save() error {
+	data, err := json.MarshalIndent(s.files, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.fileName), filepath.Base(s.fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.fileName)
+}
+
+// InputChecksum calculates the hex encoded SHA-256 of an input (uncompressed) content
+func InputChecksum(in Input) (string, error) {
+	f, err := in.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ConfigChecksum combines a file checksum with the effective (merged) configuration of the file,
+// so that a configuration change makes the file look changed too
+func ConfigChecksum(fileChecksum string, v *viper.Viper) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(fileChecksum))
+
+	if v != nil {
+		// json encoding sorts map keys, so the result is stable
+		config, err := json.Marshal(v.AllSettings())
+		if err != nil {
+			return "", err
+		}
+		hash.Write(config)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
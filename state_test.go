@@ -0,0 +1,50 @@
+package csv2table
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csv2table")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "state.json")
+	s, err := LoadStateStore(fileName)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, s.Unchanged("sales.csv", "abc"))
+
+	assert.Nil(t, s.Set("sales.csv", "abc"))
+
+	// reload from disk
+	s, err = LoadStateStore(fileName)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, s.Unchanged("sales.csv", "abc"))
+	assert.False(t, s.Unchanged("sales.csv", "def"))
+}
+
+func TestConfigChecksum(t *testing.T) {
+	v := viper.New()
+	v.Set("drop", true)
+
+	c1, err := ConfigChecksum("abc", v)
+	assert.Nil(t, err)
+
+	v.Set("drop", false)
+	c2, err := ConfigChecksum("abc", v)
+	assert.Nil(t, err)
+
+	assert.NotEqual(t, c1, c2)
+}
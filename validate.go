@@ -0,0 +1,513 @@
+package csv2table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pelletier/go-toml"
+	"github.com/spf13/viper"
+)
+
+// ConfigError is an invalid option of a configuration file
+type ConfigError struct {
+	File string // configuration file, empty if the option isn't set by a file, e.g. by an environment variable
+	Line int    // line of the option, 0 if unknown
+	Key  string // option, e.g. mapping.amount.format, empty for the errors of the whole file
+	Err  error
+}
+
+// Error formats the error as file:line: key: error
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		if e.Line > 0 {
+			b.WriteString(":" + strconv.Itoa(e.Line))
+		}
+		b.WriteString(": ")
+	}
+	if e.Key != "" {
+		b.WriteString(e.Key + ": ")
+	}
+	b.WriteString(e.Err.Error())
+
+	return b.String()
+}
+
+// Unwrap returns the underlying error
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors are the errors found by a validation
+type ConfigErrors []*ConfigError
+
+// Error lists the errors, one per line
+func (e ConfigErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ConfigValidator is the interface implemented by databases validating their configuration, see Importer.Validate
+type ConfigValidator interface {
+	// DecodeConfig decodes the options of a configuration file, returning the options it doesn't know,
+	// see DecodeSettings
+	DecodeConfig(settings map[string]interface{}) ([]string, error)
+
+	// ValidateConfig checks the merged configuration of a file, e.g. value ranges and column mappings.
+	// header holds the columns of the file, nil if unknown. The errors only need their key, the
+	// configuration file and line being found by the caller.
+	ValidateConfig(v *viper.Viper, header []string) []*ConfigError
+}
+
+// DecodeSettings decodes settings into out like viper's Unmarshal does, returning the options not matching
+// any field of out, in lower case and dot separated, e.g. mapping.amount.nulif or sources.0.hots
+func DecodeSettings(settings map[string]interface{}, out interface{}) ([]string, error) {
+	v := viper.New()
+	v.MergeConfigMap(settings)
+
+	var md mapstructure.Metadata
+	err := v.Unmarshal(out, func(c *mapstructure.DecoderConfig) {
+		c.Metadata = &md
+	})
+	if merr, ok := err.(*mapstructure.Error); ok {
+		err = fmt.Errorf("%s", strings.Join(merr.Errors, ", "))
+	}
+
+	unused := make([]string, len(md.Unused))
+	for i, key := range md.Unused {
+		unused[i] = strings.ToLower(settingKeyReplacer.Replace(key))
+	}
+	sort.Strings(unused)
+
+	return unused, err
+}
+
+// settingKeyReplacer converts the mapstructure names of map and slice elements to dot separated keys
+var settingKeyReplacer = strings.NewReplacer("[", ".", "]", "")
+
+// configDecoder decodes the options of a configuration file, see DecodeSettings
+type configDecoder func(settings map[string]interface{}) ([]string, error)
+
+// decodeGlobalOptions decodes the options of Config, read from the global configuration only
+func decodeGlobalOptions(settings map[string]interface{}) ([]string, error) {
+	config := NewConfig()
+	return DecodeSettings(settings, &config)
+}
+
+// decodeReaderOptions decodes the options of the record readers
+func decodeReaderOptions(settings map[string]interface{}) ([]string, error) {
+	var options readerOptions
+	return DecodeSettings(settings, &options)
+}
+
+// Validate checks the global configuration and the configuration of inputs, without importing them. The
+// configuration files must only have known options, with values of the expected types. The merged configuration
+// of each input must be valid: value ranges, mapped columns that exist in its header and formats matching the
+// column types. The errors are located by file, key and line. It returns nil if the configuration is valid.
+func (im *Importer) Validate(inputs ...Input) ConfigErrors {
+	var errs ConfigErrors
+	seen := make(map[string]bool)
+	add := func(found []*ConfigError) {
+		for _, err := range found {
+			if !seen[err.Error()] {
+				seen[err.Error()] = true
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	global, found := im.checkConfigFiles(im.configFiles, true)
+	add(found)
+
+	config, err := UnmarshallConfig(im.globalViper())
+	if err != nil {
+		add([]*ConfigError{{Err: err}})
+	} else {
+		add(locateErrors(global, checkGlobalOptions(config)))
+	}
+
+	if len(inputs) == 0 {
+		add(locateErrors(global, im.checkOptions(im.globalViper(), nil)))
+	}
+
+	for _, in := range inputs {
+		add(im.validateInput(global, in))
+	}
+
+	return errs
+}
+
+// validateInput checks the configuration file of an input and its merged configuration, global being
+// the global configuration files
+func (im *Importer) validateInput(global []*configFile, in Input) []*ConfigError {
+	own, errs := im.checkConfigFiles(im.inputConfigFiles(in), false)
+	files := append(append([]*configFile{}, global...), own...)
+
+	v, err := im.fileViper(in)
+	if err != nil {
+		var cerr *ConfigError
+		if errors.As(err, &cerr) && cerr.Key != "" {
+			return append(errs, locateErrors(files, []*ConfigError{cerr})...)
+		}
+		return append(errs, &ConfigError{File: in.ConfigFileName(), Err: err})
+	}
+
+	// the header is read only with valid reader options
+	var header []string
+	optionErrs := checkReaderOptions(v)
+	if len(optionErrs) == 0 {
+		header, err = readHeader(in, v)
+		if err != nil {
+			errs = append(errs, &ConfigError{File: in.String(), Err: fmt.Errorf("unable to read header, %v", err)})
+		}
+	}
+
+	errs = append(errs, locateErrors(files, optionErrs)...)
+	return append(errs, locateErrors(files, im.checkBackendOptions(v, header))...)
+}
+
+// checkOptions checks the merged options of the readers and of the database
+func (im *Importer) checkOptions(v *viper.Viper, header []string) []*ConfigError {
+	return append(checkReaderOptions(v), im.checkBackendOptions(v, header)...)
+}
+
+// checkBackendOptions checks the merged options of the database, if it validates its configuration
+func (im *Importer) checkBackendOptions(v *viper.Viper, header []string) []*ConfigError {
+	if validator, ok := im.newService().(ConfigValidator); ok {
+		return validator.ValidateConfig(v, header)
+	}
+
+	return nil
+}
+
+// checkConfigFiles reads configuration files and checks that their options are known and of the expected types.
+// The options of Config are allowed in the global configuration files only.
+func (im *Importer) checkConfigFiles(fileNames []string, global bool) ([]*configFile, []*ConfigError) {
+	// without a validating database, its options are all known
+	backend := func(settings map[string]interface{}) ([]string, error) {
+		return nil, nil
+	}
+	if validator, ok := im.newService().(ConfigValidator); ok {
+		backend = validator.DecodeConfig
+	}
+
+	decoders := []configDecoder{decodeReaderOptions, backend}
+	if global {
+		decoders = append(decoders, decodeGlobalOptions)
+	}
+
+	var files []*configFile
+	var errs []*ConfigError
+	for _, fileName := range fileNames {
+		// the included files are checked on their own
+		chain, err := readConfigChain(fileName)
+		if err != nil {
+			errs = append(errs, &ConfigError{File: fileName, Err: err})
+			continue
+		}
+
+		for _, file := range chain {
+			files = append(files, file)
+			errs = append(errs, checkConfigFile(file, decoders, global)...)
+		}
+	}
+
+	return files, errs
+}
+
+// checkConfigFile checks the options of a configuration file with decoders, see checkSettings.
+// The options of the mapping templates are checked like column mappings.
+func checkConfigFile(file *configFile, decoders []configDecoder, global bool) []*ConfigError {
+	file.lines = make(map[string]int)
+
+	var errs []*ConfigError
+	if strings.EqualFold(filepath.Ext(file.name), ".toml") {
+		errs = tomlLines(file.name, file.lines)
+	}
+	errs = append(errs, checkSettings(file.settings, append(decoders, decodeConfigDirectives), global)...)
+
+	if templates, ok := file.settings["templates"]; ok {
+		for _, err := range checkSettings(map[string]interface{}{"mapping": templates}, decoders, global) {
+			err.Key = "templates" + strings.TrimPrefix(err.Key, "mapping")
+			errs = append(errs, err)
+		}
+	}
+
+	for _, err := range errs {
+		err.File = file.name
+		if err.Line == 0 {
+			err.Line = file.line(err.Key)
+		}
+	}
+
+	return errs
+}
+
+// configDirectives holds the options structuring the configuration files, see applyTemplates
+type configDirectives struct {
+	Templates map[string]map[string]interface{}
+	Mapping   map[string]struct{ Use string }
+}
+
+// decodeConfigDirectives decodes the options structuring the configuration files
+func decodeConfigDirectives(settings map[string]interface{}) ([]string, error) {
+	var directives configDirectives
+	return DecodeSettings(settings, &directives)
+}
+
+// errUnknownOption is the error of the options not known by any of the decoders
+var errUnknownOption = errors.New("unknown option")
+
+// checkSettings decodes each option of settings, reporting the options not known by any of the decoders.
+// global tells whether settings are read from a global configuration file.
+func checkSettings(settings map[string]interface{}, decoders []configDecoder, global bool) []*ConfigError {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []*ConfigError
+	for _, key := range keys {
+		option := map[string]interface{}{key: settings[key]}
+
+		known := false
+		var unknown map[string]bool // nested options not known by any of the decoders knowing the option
+		for _, decode := range decoders {
+			unused, err := decode(option)
+			if containsString(unused, key) {
+				continue
+			}
+			known = true
+
+			if err != nil {
+				errs = append(errs, &ConfigError{Key: key, Err: err})
+			}
+
+			nested := make(map[string]bool)
+			for _, u := range unused {
+				if unknown == nil || unknown[u] {
+					nested[u] = true
+				}
+			}
+			unknown = nested
+		}
+
+		if !known {
+			err := errUnknownOption
+			if !global && isGlobalOption(option, key) {
+				err = errors.New("option allowed in the global configuration file only")
+			}
+			errs = append(errs, &ConfigError{Key: key, Err: err})
+		}
+
+		nested := make([]string, 0, len(unknown))
+		for u := range unknown {
+			nested = append(nested, u)
+		}
+		sort.Strings(nested)
+		for _, u := range nested {
+			errs = append(errs, &ConfigError{Key: u, Err: errUnknownOption})
+		}
+	}
+
+	return errs
+}
+
+// isGlobalOption checks whether an option is an option of Config
+func isGlobalOption(option map[string]interface{}, key string) bool {
+	unused, _ := decodeGlobalOptions(option)
+	return !containsString(unused, key)
+}
+
+// containsString checks whether a list holds a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkGlobalOptions checks the values of the generic options
+func checkGlobalOptions(config Config) []*ConfigError {
+	var errs []*ConfigError
+	invalid := func(key string, err error) {
+		errs = append(errs, &ConfigError{Key: key, Err: err})
+	}
+
+	if config.ParallelFiles < 1 {
+		invalid("parallelfiles", errors.New("parallelFiles must be at least 1"))
+	}
+	if config.ArchiveRetention < 0 {
+		invalid("archiveretention", errors.New("archiveRetention can't be negative"))
+	}
+	if config.WatchStable < 0 {
+		invalid("watchstable", errors.New("watchStable can't be negative"))
+	}
+	if config.WatchHeartbeat < 0 {
+		invalid("watchheartbeat", errors.New("watchHeartbeat can't be negative"))
+	}
+
+	patterns := map[string][]string{"includefiles": config.IncludeFiles, "exclude": config.Exclude}
+	for i, file := range config.Files {
+		patterns["files."+strconv.Itoa(i)+".pattern"] = []string{file.Pattern}
+	}
+	for key, list := range patterns {
+		for _, pattern := range list {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				invalid(key, fmt.Errorf("invalid pattern %s, %v", pattern, err))
+			}
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Key < errs[j].Key })
+
+	return errs
+}
+
+// readHeader reads the header of an input. The data types of typed formats are set in v, like for an import.
+func readHeader(in Input, v *viper.Viper) ([]string, error) {
+	f, err := in.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rr, err := newRecordReader(inputFormat(in.Name, v), f, v)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := rr.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	if t, ok := rr.(typedReader); ok {
+		v.Set("dataTypes", t.DataTypes())
+	}
+
+	return rr.Read()
+}
+
+// locateErrors sets the file and line of the errors of merged options: the last of files setting the option
+func locateErrors(files []*configFile, errs []*ConfigError) []*ConfigError {
+	for _, err := range errs {
+		if err.Key == "" {
+			continue
+		}
+
+		for i := len(files) - 1; i >= 0; i-- {
+			if files[i].has(err.Key) {
+				err.File = files[i].name
+				err.Line = files[i].line(err.Key)
+				break
+			}
+		}
+	}
+
+	return errs
+}
+
+// has checks whether the file sets an option
+func (f *configFile) has(key string) bool {
+	var value interface{} = f.settings
+	for _, part := range strings.Split(key, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return false
+			}
+			value = v[i]
+		case []map[string]interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return false
+			}
+			value = v[i]
+		default:
+			return false
+		}
+
+		if value == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// line returns the line of an option, or of its closest parent if the option itself has no known line
+func (f *configFile) line(key string) int {
+	for key != "" {
+		if line, exists := f.lines[key]; exists {
+			return line
+		}
+
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+
+	return 0
+}
+
+// tomlLines finds the line of the options of a toml file, by lower case key. Options differing only by case
+// are reported, one of them being silently ignored as viper keys are case insensitive.
+func tomlLines(fileName string, lines map[string]int) []*ConfigError {
+	tree, err := toml.LoadFile(fileName)
+	if err != nil {
+		// already reported by viper
+		return nil
+	}
+
+	var errs []*ConfigError
+	walkToml(tree, "", lines, &errs)
+
+	return errs
+}
+
+// walkToml records the line of the options of a toml tree, prefix being the key of the tree
+func walkToml(tree *toml.Tree, prefix string, lines map[string]int, errs *[]*ConfigError) {
+	keys := tree.Keys()
+	sort.Strings(keys)
+
+	seen := make(map[string]string)
+	for _, k := range keys {
+		key := prefix + strings.ToLower(k)
+		line := tree.GetPositionPath([]string{k}).Line
+
+		if other, exists := seen[key]; exists {
+			*errs = append(*errs, &ConfigError{Key: key, Line: line,
+				Err: fmt.Errorf("%s and %s are the same option, options are case insensitive", other, k)})
+			continue
+		}
+		seen[key] = k
+		lines[key] = line
+
+		switch value := tree.GetPath([]string{k}).(type) {
+		case *toml.Tree:
+			walkToml(value, key+".", lines, errs)
+		case []*toml.Tree:
+			for i, t := range value {
+				walkToml(t, key+"."+strconv.Itoa(i)+".", lines, errs)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package csv2table
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// validatingService is a DbService knowing the table and mapping options
+type validatingService struct {
+	tableService
+}
+
+func (s *validatingService) DecodeConfig(settings map[string]interface{}) ([]string, error) {
+	var config struct {
+		Table          string
+		BulkInsertSize int
+		Mapping        map[string]struct{ NullIf []string }
+	}
+	return DecodeSettings(settings, &config)
+}
+
+func (s *validatingService) ValidateConfig(v *viper.Viper, header []string) []*ConfigError {
+	var errs []*ConfigError
+	if v.GetInt("bulkInsertSize") < 0 {
+		errs = append(errs, &ConfigError{Key: "bulkinsertsize", Err: errors.New("negative")})
+	}
+
+	cols := make(map[string]bool)
+	for _, col := range SanitizeNames(header) {
+		cols[col] = true
+	}
+	for col := range v.GetStringMap("mapping") {
+		if header != nil && !cols[col] {
+			errs = append(errs, &ConfigError{Key: "mapping." + col, Err: errors.New("not in the header")})
+		}
+	}
+
+	return errs
+}
+
+// errorStrings returns the messages of errs, with the file names relative to dir
+func errorStrings(dir string, errs ConfigErrors) []string {
+	var messages []string
+	for _, err := range errs {
+		if rel, rerr := filepath.Rel(dir, err.File); rerr == nil && err.File != "" {
+			err.File = rel
+		}
+		messages = append(messages, err.Error())
+	}
+
+	return messages
+}
+
+func TestDecodeSettings(t *testing.T) {
+	var config struct {
+		Port    int
+		Mapping map[string]struct{ Format string }
+		Sources []struct{ Host string }
+	}
+
+	settings := map[string]interface{}{
+		"port":    "3306",
+		"mapping": map[string]interface{}{"amount": map[string]interface{}{"format": "1,2", "nulif": []interface{}{"x"}}},
+		"sources": []interface{}{map[string]interface{}{"hots": "a"}},
+		"verbose": true,
+	}
+	unused, err := DecodeSettings(settings, &config)
+	assert.Nil(t, err)
+	assert.Equal(t, config.Port, 3306)
+	assert.Equal(t, unused, []string{"mapping.amount.nulif", "sources.0.hots", "verbose"})
+
+	_, err = DecodeSettings(map[string]interface{}{"port": "abc"}, &config)
+	assert.NotNil(t, err)
+}
+
+func TestImporterValidate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"csv2table.toml": "parallelFiles = 2\ndelimiter = \",\"\nbulkInsertSize = -1\nverbos = true\n",
+		"people.csv":     "id,name\n1,Ana\n",
+		"people.toml": "table = \"persons\"\nparallelFiles = 2\n\n[mapping.name]\nnullIf = [\"-\"]\nnullIF = [\"x\"]\n" +
+			"\n[mapping.age]\nnulif = [\"\"]\n",
+		"stock.csv":  "id;qty\n",
+		"stock.toml": "bulkInsertSize = \"abc\"\nencoding = \"klingon\"\n",
+	})
+
+	newService := func() DbService { return &validatingService{} }
+	im, err := NewImporter(WithBackend(newService), WithConfigFile(filepath.Join(dir, "csv2table.toml")))
+	assert.Nil(t, err)
+
+	errs := im.Validate(
+		Input{Name: "people.csv", Path: filepath.Join(dir, "people.csv")},
+		Input{Name: "stock.csv", Path: filepath.Join(dir, "stock.csv")},
+	)
+	assert.Equal(t, errorStrings(dir, errs), []string{
+		"csv2table.toml:4: verbos: unknown option",
+		"people.toml:5: mapping.name.nullif: nullIF and nullIf are the same option, options are case insensitive",
+		"people.toml:9: mapping.age.nulif: unknown option",
+		"people.toml:2: parallelfiles: option allowed in the global configuration file only",
+		"csv2table.toml:3: bulkinsertsize: negative",
+		"people.toml:8: mapping.age: not in the header",
+		"stock.toml:1: bulkinsertsize: cannot parse 'BulkInsertSize' as int: strconv.ParseInt: parsing \"abc\": invalid syntax",
+		"stock.toml:2: encoding: unknown encoding klingon",
+	})
+
+	// a valid configuration
+	im, err = NewImporter(WithBackend(newService))
+	assert.Nil(t, err)
+	assert.Nil(t, im.Validate(Input{Name: "other.csv", Path: filepath.Join(dir, "people.csv")}))
+}
+
+func TestImporterStrict(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"csv2table.toml": "strict = true\n",
+		"sales.csv":      "id;amount\n1;10\n",
+		"sales.toml":     "table = \"sales\"\n[mapping.amount]\nnulif = [\"\"]\n",
+		"stock.csv":      "id;qty\n1;5\n",
+		"stock.toml":     "table = \"stock\"\n",
+	})
+
+	backend := &tableBackend{tables: make(map[string][][]string)}
+	newService := func() DbService { return &validatingService{tableService{backend: backend}} }
+	im, err := NewImporter(WithBackend(newService), WithConfigFile(filepath.Join(dir, "csv2table.toml")))
+	assert.Nil(t, err)
+
+	// the file with an invalid configuration isn't imported
+	statuses, err := im.ImportDir(context.Background(), dir)
+	assert.Nil(t, err)
+	assert.Equal(t, statuses[0].Status, StatusFailed)
+	assert.Contains(t, fmt.Sprint(statuses[0].Error), "mapping.amount.nulif: unknown option")
+	assert.Equal(t, statuses[1].Status, StatusImported)
+	assert.Equal(t, backend.tables, map[string][][]string{"stock": {{"1", "5"}}})
+
+	// an invalid global configuration fails the importer
+	writeFiles(t, dir, map[string]string{"csv2table.toml": "strict = true\nparalelFiles = 2\n"})
+	_, err = NewImporter(WithBackend(newService), WithConfigFile(filepath.Join(dir, "csv2table.toml")))
+	assert.NotNil(t, err)
+
+	// invalid types are located even without strict mode
+	writeFiles(t, dir, map[string]string{"csv2table.toml": "parallelFiles = \"many\"\n"})
+	_, err = NewImporter(WithBackend(newService), WithConfigFile(filepath.Join(dir, "csv2table.toml")))
+	assert.Contains(t, fmt.Sprint(err), "csv2table.toml:1: parallelfiles: ")
+}
+
+func TestImporterValidateIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"csv2table.toml": "include = [\"common.toml\"]\n[templates.german_date]\nnulif = [\"\"]\n",
+		"common.toml":    "table = \"sales\"\nverbos = true\n",
+		"sales.csv":      "id;day\n1;01.02.2026\n",
+		"sales.toml":     "[mapping.day]\nuse = \"german_date\"\n[mapping.id]\nuse = \"us_date\"\n",
+	})
+
+	newService := func() DbService { return &validatingService{} }
+	im, err := NewImporter(WithBackend(newService), WithConfigFile(filepath.Join(dir, "csv2table.toml")))
+	assert.Nil(t, err)
+
+	// the included files are checked on their own
+	errs := im.Validate(Input{Name: "sales.csv", Path: filepath.Join(dir, "sales.csv")})
+	assert.Equal(t, errorStrings(dir, errs), []string{
+		"common.toml:2: verbos: unknown option",
+		"csv2table.toml:3: templates.german_date.nulif: unknown option",
+		"sales.toml:4: mapping.id.use: unknown template us_date",
+	})
+}
@@ -0,0 +1,599 @@
+package csv2table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// BIFF8 record types
+const (
+	recFormula    = 0x0006
+	recEOF        = 0x000A
+	recDateMode   = 0x0022
+	recFilePass   = 0x002F
+	recContinue   = 0x003C
+	recBoundSheet = 0x0085
+	recMulRk      = 0x00BD
+	recXF         = 0x00E0
+	recSST        = 0x00FC
+	recLabelSST   = 0x00FD
+	recNumber     = 0x0203
+	recLabel      = 0x0204
+	recBoolErr    = 0x0205
+	recString     = 0x0207
+	recRK         = 0x027E
+	recFormat     = 0x041E
+	recBOF        = 0x0809
+)
+
+// biff8Version is the version of the BOF records of Excel 97-2003 workbooks
+const biff8Version = 0x0600
+
+// xlsErrors holds the text of the error values of the cells, by code
+var xlsErrors = map[byte]string{
+	0x00: "#NULL!",
+	0x07: "#DIV/0!",
+	0x0F: "#VALUE!",
+	0x17: "#REF!",
+	0x1D: "#NAME?",
+	0x24: "#NUM!",
+	0x2A: "#N/A",
+}
+
+// errTruncatedRecord is returned for records shorter than their content
+var errTruncatedRecord = errors.New("invalid xls file, truncated record")
+
+// xlsReader reads the rows of a worksheet of a legacy Excel workbook (.xls, Excel 97-2003), like xlsxReader:
+// numbers are read unformatted and cells with a date format are converted to the mysql formats.
+type xlsReader struct {
+	cells map[int]map[int]string // cell values by row and column, 0 based
+	rows  []int                  // row numbers, in order
+	next  int                    // index in rows of the next row
+	width int                    // number of columns of the header
+}
+
+// xlsWorkbook holds the global records of a workbook needed to read the cells
+type xlsWorkbook struct {
+	stream   []byte
+	date1904 bool
+	sst      []string
+	formats  map[int]string // custom number formats by id
+	xfs      []int          // number format id by XF index
+	sheets   []xlsSheet
+
+	dateKinds map[int]int // date kind by XF index
+}
+
+// xlsSheet is a sheet of the workbook, its substream starting at offset
+type xlsSheet struct {
+	name   string
+	offset int
+	kind   byte // 0 for worksheets
+}
+
+// xlsRecord is a record with the data of its CONTINUE records, one chunk each
+type xlsRecord struct {
+	typ    uint16
+	chunks [][]byte
+}
+
+// newXlsReader opens a workbook and selects a sheet by name, or by index (1 based) if sheet is empty.
+// The first sheet is read by default. The whole sheet is read at once.
+func newXlsReader(r io.Reader, sheet string, sheetIndex int, skipRows int) (*xlsReader, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := workbookStream(data)
+	if err != nil {
+		return nil, err
+	}
+
+	wb := &xlsWorkbook{stream: stream, formats: make(map[int]string), dateKinds: make(map[int]int)}
+	err = wb.readGlobals()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected *xlsSheet
+	if sheet == "" {
+		if sheetIndex < 1 {
+			sheetIndex = 1
+		}
+		if sheetIndex > len(wb.sheets) {
+			return nil, fmt.Errorf("sheet %d not found, the workbook has %d sheets", sheetIndex, len(wb.sheets))
+		}
+		selected = &wb.sheets[sheetIndex-1]
+	} else {
+		for i := range wb.sheets {
+			if wb.sheets[i].name == sheet {
+				selected = &wb.sheets[i]
+				break
+			}
+		}
+		if selected == nil {
+			return nil, fmt.Errorf("sheet %s not found", sheet)
+		}
+	}
+	if selected.kind != 0 {
+		return nil, fmt.Errorf("sheet %s is not a worksheet", selected.name)
+	}
+
+	x := &xlsReader{cells: make(map[int]map[int]string)}
+	err = wb.readSheet(selected.offset, func(row int, col int, value string) {
+		if row < skipRows || value == "" {
+			return
+		}
+		if x.cells[row] == nil {
+			x.cells[row] = make(map[int]string)
+			x.rows = append(x.rows, row)
+		}
+		x.cells[row][col] = value
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(x.rows)
+
+	return x, nil
+}
+
+// Read returns the next row, padded to the number of columns of the header. Empty rows are skipped.
+// A row with values to the right of the last header column is an error.
+func (x *xlsReader) Read() ([]string, error) {
+	for x.next < len(x.rows) {
+		row := x.rows[x.next]
+		x.next++
+
+		cells := x.cells[row]
+		delete(x.cells, row)
+
+		width := 0
+		for col := range cells {
+			if col >= width {
+				width = col + 1
+			}
+		}
+		cols := make([]string, width)
+		for col, value := range cells {
+			cols[col] = value
+		}
+
+		cols, err := fitRow(cols, &x.width, row+1)
+		if err != nil {
+			return nil, err
+		}
+		if cols != nil {
+			return cols, nil
+		}
+	}
+
+	return nil, io.EOF
+}
+
+// workbookStream returns the workbook stream of an OLE2 compound file
+func workbookStream(data []byte) ([]byte, error) {
+	doc, err := mscfb.New(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not an xls file, %v", err)
+	}
+
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		switch entry.Name {
+		case "Workbook":
+			return ioutil.ReadAll(entry)
+		case "Book":
+			return nil, errors.New("xls files older than Excel 97 are not supported")
+		}
+	}
+
+	return nil, errors.New("not an xls file, the workbook stream is missing")
+}
+
+// readGlobals reads the workbook globals substream, at the start of the stream
+func (wb *xlsWorkbook) readGlobals() error {
+	return wb.readSubstream(0, func(rec *xlsRecord) error {
+		data := rec.chunks[0]
+
+		switch rec.typ {
+		case recFilePass:
+			return errors.New("encrypted xls files are not supported")
+		case recDateMode:
+			if len(data) < 2 {
+				return errTruncatedRecord
+			}
+			wb.date1904 = binary.LittleEndian.Uint16(data) == 1
+		case recFormat:
+			if len(data) < 2 {
+				return errTruncatedRecord
+			}
+			r := &xlsChunkReader{chunks: [][]byte{data[2:]}}
+			code, err := r.unicodeString(2)
+			if err != nil {
+				return err
+			}
+			wb.formats[int(binary.LittleEndian.Uint16(data))] = code
+		case recXF:
+			if len(data) < 4 {
+				return errTruncatedRecord
+			}
+			wb.xfs = append(wb.xfs, int(binary.LittleEndian.Uint16(data[2:])))
+		case recBoundSheet:
+			if len(data) < 8 {
+				return errTruncatedRecord
+			}
+			r := &xlsChunkReader{chunks: [][]byte{data[6:]}}
+			name, err := r.unicodeString(1)
+			if err != nil {
+				return err
+			}
+			wb.sheets = append(wb.sheets, xlsSheet{
+				name:   name,
+				offset: int(binary.LittleEndian.Uint32(data)),
+				kind:   data[5],
+			})
+		case recSST:
+			return wb.readSST(rec)
+		}
+
+		return nil
+	})
+}
+
+// readSST reads the shared strings table, split into CONTINUE records when longer than a record
+func (wb *xlsWorkbook) readSST(rec *xlsRecord) error {
+	if len(rec.chunks[0]) < 8 {
+		return errTruncatedRecord
+	}
+
+	count := int(binary.LittleEndian.Uint32(rec.chunks[0][4:]))
+	r := &xlsChunkReader{chunks: rec.chunks}
+	r.pos = 8
+
+	for i := 0; i < count; i++ {
+		s, err := r.unicodeString(2)
+		if err != nil {
+			return err
+		}
+		wb.sst = append(wb.sst, s)
+	}
+
+	return nil
+}
+
+// readSheet reads the cells of the worksheet substream starting at offset, calling cell for each value
+func (wb *xlsWorkbook) readSheet(offset int, cell func(row int, col int, value string)) error {
+	// the string result of a formula follows it in a STRING record
+	var formulaRow, formulaCol = -1, -1
+
+	return wb.readSubstream(offset, func(rec *xlsRecord) error {
+		data := rec.chunks[0]
+
+		// the cell records start with the row, the column and the XF index
+		var row, col, xf int
+		switch rec.typ {
+		case recLabelSST, recLabel, recNumber, recRK, recBoolErr, recFormula:
+			if len(data) < 6 {
+				return errTruncatedRecord
+			}
+			row = int(binary.LittleEndian.Uint16(data))
+			col = int(binary.LittleEndian.Uint16(data[2:]))
+			xf = int(binary.LittleEndian.Uint16(data[4:]))
+		}
+
+		switch rec.typ {
+		case recLabelSST:
+			if len(data) < 10 {
+				return errTruncatedRecord
+			}
+			i := int(binary.LittleEndian.Uint32(data[6:]))
+			if i >= len(wb.sst) {
+				return fmt.Errorf("invalid xls file, shared string %d not found", i)
+			}
+			cell(row, col, wb.sst[i])
+		case recLabel:
+			r := &xlsChunkReader{chunks: append([][]byte{data[6:]}, rec.chunks[1:]...)}
+			s, err := r.unicodeString(2)
+			if err != nil {
+				return err
+			}
+			cell(row, col, s)
+		case recNumber:
+			if len(data) < 14 {
+				return errTruncatedRecord
+			}
+			return wb.numberCell(row, col, xf, math.Float64frombits(binary.LittleEndian.Uint64(data[6:])), cell)
+		case recRK:
+			if len(data) < 10 {
+				return errTruncatedRecord
+			}
+			return wb.numberCell(row, col, xf, rkNumber(binary.LittleEndian.Uint32(data[6:])), cell)
+		case recMulRk:
+			// row, first column, (XF index, RK value) of each column, last column
+			if len(data) < 6 || (len(data)-6)%6 != 0 {
+				return errTruncatedRecord
+			}
+			row = int(binary.LittleEndian.Uint16(data))
+			first := int(binary.LittleEndian.Uint16(data[2:]))
+			for i := 0; i < (len(data)-6)/6; i++ {
+				item := data[4+i*6:]
+				err := wb.numberCell(row, first+i, int(binary.LittleEndian.Uint16(item)),
+					rkNumber(binary.LittleEndian.Uint32(item[2:])), cell)
+				if err != nil {
+					return err
+				}
+			}
+		case recBoolErr:
+			if len(data) < 8 {
+				return errTruncatedRecord
+			}
+			cell(row, col, boolErrValue(data[6], data[7] == 1))
+		case recFormula:
+			if len(data) < 14 {
+				return errTruncatedRecord
+			}
+			result := data[6:14]
+			if result[6] != 0xFF || result[7] != 0xFF {
+				return wb.numberCell(row, col, xf, math.Float64frombits(binary.LittleEndian.Uint64(result)), cell)
+			}
+			switch result[0] {
+			case 0:
+				formulaRow, formulaCol = row, col
+			case 1:
+				cell(row, col, boolErrValue(result[2], false))
+			case 2:
+				cell(row, col, boolErrValue(result[2], true))
+			}
+		case recString:
+			if formulaRow < 0 {
+				return nil
+			}
+			r := &xlsChunkReader{chunks: rec.chunks}
+			s, err := r.unicodeString(2)
+			if err != nil {
+				return err
+			}
+			cell(formulaRow, formulaCol, s)
+			formulaRow, formulaCol = -1, -1
+		}
+
+		return nil
+	})
+}
+
+// numberCell formats a number by the date kind of the format of its XF, like the numbers of xlsx files
+func (wb *xlsWorkbook) numberCell(row int, col int, xf int, number float64, cell func(int, int, string)) error {
+	kind := wb.dateKind(xf)
+	if kind == notDate {
+		cell(row, col, strconv.FormatFloat(number, 'f', -1, 64))
+		return nil
+	}
+
+	value, err := excelDate(number, kind, wb.date1904)
+	if err != nil {
+		return fmt.Errorf("invalid date in row %d column %d, %v", row+1, col+1, err)
+	}
+	cell(row, col, value)
+
+	return nil
+}
+
+// dateKind returns the date kind of the number format of an XF
+func (wb *xlsWorkbook) dateKind(xf int) int {
+	kind, exists := wb.dateKinds[xf]
+	if exists {
+		return kind
+	}
+
+	kind = notDate
+	if xf < len(wb.xfs) {
+		format := wb.xfs[xf]
+		if code, custom := wb.formats[format]; custom {
+			kind = numFmtDateKind(code)
+		} else {
+			kind = builtInDateKind(format)
+		}
+	}
+	wb.dateKinds[xf] = kind
+
+	return kind
+}
+
+// readSubstream reads the records of the substream starting at offset, from its BOF record to its EOF record.
+// The records of embedded substreams, e.g. charts, are skipped.
+func (wb *xlsWorkbook) readSubstream(offset int, process func(rec *xlsRecord) error) error {
+	depth := 0
+	pos := offset
+	var rec *xlsRecord
+
+	// the record is processed once its CONTINUE records are read
+	flush := func() error {
+		if rec == nil {
+			return nil
+		}
+		err := process(rec)
+		rec = nil
+		return err
+	}
+
+	for {
+		if pos+4 > len(wb.stream) {
+			return errors.New("invalid xls file, unexpected end of the workbook stream")
+		}
+		typ := binary.LittleEndian.Uint16(wb.stream[pos:])
+		size := int(binary.LittleEndian.Uint16(wb.stream[pos+2:]))
+		pos += 4
+		if pos+size > len(wb.stream) {
+			return errTruncatedRecord
+		}
+		data := wb.stream[pos : pos+size]
+		pos += size
+
+		if typ == recContinue {
+			if rec != nil {
+				rec.chunks = append(rec.chunks, data)
+			}
+			continue
+		}
+
+		err := flush()
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case recBOF:
+			if depth == 0 && (len(data) < 2 || binary.LittleEndian.Uint16(data) != biff8Version) {
+				return errors.New("only xls files of Excel 97 and later are supported")
+			}
+			depth++
+		case recEOF:
+			depth--
+			if depth <= 0 {
+				return nil
+			}
+		default:
+			if depth == 1 {
+				rec = &xlsRecord{typ: typ, chunks: [][]byte{data}}
+			}
+		}
+	}
+}
+
+// xlsChunkReader reads the data of a record split in chunks by CONTINUE records
+type xlsChunkReader struct {
+	chunks [][]byte
+	i      int // current chunk
+	pos    int // position in the current chunk
+}
+
+// read returns the next n bytes, that may span several chunks
+func (r *xlsChunkReader) read(n int) ([]byte, error) {
+	var b []byte
+	for len(b) < n {
+		if r.i >= len(r.chunks) {
+			return nil, errTruncatedRecord
+		}
+		chunk := r.chunks[r.i]
+		if r.pos >= len(chunk) {
+			r.i++
+			r.pos = 0
+			continue
+		}
+
+		end := r.pos + n - len(b)
+		if end > len(chunk) {
+			end = len(chunk)
+		}
+		b = append(b, chunk[r.pos:end]...)
+		r.pos = end
+	}
+
+	return b, nil
+}
+
+// unicodeString reads a BIFF8 unicode string whose length is stored in lenSize bytes. When the characters
+// continue in the next chunk, the chunk starts with the option flags of the remaining characters.
+func (r *xlsChunkReader) unicodeString(lenSize int) (string, error) {
+	header, err := r.read(lenSize + 1)
+	if err != nil {
+		return "", err
+	}
+	count := int(header[0])
+	if lenSize == 2 {
+		count = int(binary.LittleEndian.Uint16(header))
+	}
+	flags := header[lenSize]
+
+	// rich text runs and phonetic data, after the characters
+	var extra int
+	if flags&0x08 != 0 {
+		b, err := r.read(2)
+		if err != nil {
+			return "", err
+		}
+		extra += 4 * int(binary.LittleEndian.Uint16(b))
+	}
+	if flags&0x04 != 0 {
+		b, err := r.read(4)
+		if err != nil {
+			return "", err
+		}
+		extra += int(binary.LittleEndian.Uint32(b))
+	}
+
+	units := make([]uint16, 0, count)
+	for len(units) < count {
+		if r.i >= len(r.chunks) {
+			return "", errTruncatedRecord
+		}
+		chunk := r.chunks[r.i]
+		if r.pos >= len(chunk) {
+			r.i++
+			if r.i >= len(r.chunks) || len(r.chunks[r.i]) == 0 {
+				return "", errTruncatedRecord
+			}
+			flags = r.chunks[r.i][0]
+			r.pos = 1
+			continue
+		}
+
+		if flags&0x01 == 0 {
+			units = append(units, uint16(chunk[r.pos]))
+			r.pos++
+		} else {
+			if r.pos+2 > len(chunk) {
+				return "", errTruncatedRecord
+			}
+			units = append(units, binary.LittleEndian.Uint16(chunk[r.pos:]))
+			r.pos += 2
+		}
+	}
+
+	if extra > 0 {
+		_, err = r.read(extra)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return string(utf16.Decode(units)), nil
+}
+
+// rkNumber decodes an RK value: a 30 bit integer or the high bits of a float, possibly multiplied by 100
+func rkNumber(rk uint32) float64 {
+	var number float64
+	if rk&0x02 != 0 {
+		number = float64(int32(rk) >> 2)
+	} else {
+		number = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		number /= 100
+	}
+
+	return number
+}
+
+// boolErrValue returns the value of a boolean cell, 1 or 0 like in xlsx files, or the text of an error
+func boolErrValue(value byte, isError bool) string {
+	if isError {
+		return xlsErrors[value]
+	}
+	if value != 0 {
+		return "1"
+	}
+
+	return "0"
+}
@@ -0,0 +1,328 @@
+package csv2table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSheet is a worksheet of a test workbook, with its cell records
+type testSheet struct {
+	name    string
+	records [][]byte
+}
+
+// le16 and le32 encode little endian integers
+func le16(v int) []byte {
+	return binary.LittleEndian.AppendUint16(nil, uint16(v))
+}
+
+func le32(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+// biffRecord encodes a BIFF record
+func biffRecord(typ uint16, data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	return bytes.Join([][]byte{le16(int(typ)), le16(len(body)), body}, nil)
+}
+
+// biffString encodes a unicode string, compressed if possible, its length stored in lenSize bytes
+func biffString(s string, lenSize int) []byte {
+	units := utf16.Encode([]rune(s))
+	var b []byte
+	if lenSize == 1 {
+		b = []byte{byte(len(units))}
+	} else {
+		b = le16(len(units))
+	}
+
+	wide := false
+	for _, u := range units {
+		wide = wide || u > 0xFF
+	}
+	if !wide {
+		b = append(b, 0)
+		for _, u := range units {
+			b = append(b, byte(u))
+		}
+		return b
+	}
+
+	b = append(b, 1)
+	for _, u := range units {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// cell encodes the row, the column and the XF index starting the cell records
+func cell(row int, col int, xf int) []byte {
+	return bytes.Join([][]byte{le16(row), le16(col), le16(xf)}, nil)
+}
+
+func float(f float64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f))
+}
+
+// newTestXls creates an Excel 97-2003 workbook, with the shared strings table sst, split into CONTINUE records
+// by chunk, and the XFs 0 (general), 1 (built-in date format) and 2 (custom date and time format)
+func newTestXls(sst [][]byte, sheets ...testSheet) []byte {
+	var stream []byte
+	stream = append(stream, biffRecord(recBOF, le16(biff8Version), le16(0x0005), make([]byte, 12))...)
+	stream = append(stream, biffRecord(recDateMode, le16(0))...)
+	stream = append(stream, biffRecord(recFormat, le16(164), biffString("dd.mm.yyyy hh:mm", 2))...)
+	for _, format := range []int{0, 14, 164} {
+		stream = append(stream, biffRecord(recXF, le16(0), le16(format), make([]byte, 16))...)
+	}
+
+	// the offsets of the sheets are patched once known
+	var offsets []int
+	for _, sheet := range sheets {
+		offsets = append(offsets, len(stream)+4)
+		stream = append(stream, biffRecord(recBoundSheet, le32(0), []byte{0, 0}, biffString(sheet.name, 1))...)
+	}
+
+	if len(sst) > 0 {
+		stream = append(stream, biffRecord(recSST, sst[0])...)
+		for _, chunk := range sst[1:] {
+			stream = append(stream, biffRecord(recContinue, chunk)...)
+		}
+	}
+	stream = append(stream, biffRecord(recEOF)...)
+
+	for i, sheet := range sheets {
+		binary.LittleEndian.PutUint32(stream[offsets[i]:], uint32(len(stream)))
+		stream = append(stream, biffRecord(recBOF, le16(biff8Version), le16(0x0010), make([]byte, 12))...)
+		for _, rec := range sheet.records {
+			stream = append(stream, rec...)
+		}
+		stream = append(stream, biffRecord(recEOF)...)
+	}
+
+	return compoundFile("Workbook", stream)
+}
+
+// compoundFile creates an OLE2 compound file with a single stream: the FAT in sector 0,
+// the directory in sector 1 and the stream from sector 2. The stream is padded to be stored in sectors.
+func compoundFile(name string, stream []byte) []byte {
+	const sectorSize = 512
+	const endOfChain, freeSect, noStream = 0xFFFFFFFE, 0xFFFFFFFF, 0xFFFFFFFF
+
+	if len(stream) < 4096 {
+		stream = append(stream, make([]byte, 4096-len(stream))...)
+	}
+	sectors := (len(stream) + sectorSize - 1) / sectorSize
+	stream = append(stream, make([]byte, sectors*sectorSize-len(stream))...)
+
+	header := make([]byte, sectorSize)
+	copy(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	put16 := func(b []byte, pos int, v int) { binary.LittleEndian.PutUint16(b[pos:], uint16(v)) }
+	put32 := func(b []byte, pos int, v uint32) { binary.LittleEndian.PutUint32(b[pos:], v) }
+	put16(header, 24, 0x3E)
+	put16(header, 26, 3)
+	put16(header, 28, 0xFFFE)
+	put16(header, 30, 9)
+	put16(header, 32, 6)
+	put32(header, 44, 1)
+	put32(header, 48, 1)
+	put32(header, 56, 4096)
+	put32(header, 60, endOfChain)
+	put32(header, 68, endOfChain)
+	for i := 0; i < 109; i++ {
+		put32(header, 76+i*4, freeSect)
+	}
+	put32(header, 76, 0)
+
+	fat := make([]byte, sectorSize)
+	for i := 0; i < sectorSize/4; i++ {
+		put32(fat, i*4, freeSect)
+	}
+	put32(fat, 0, 0xFFFFFFFD)
+	put32(fat, 4, endOfChain)
+	for i := 0; i < sectors; i++ {
+		next := uint32(i + 3)
+		if i == sectors-1 {
+			next = endOfChain
+		}
+		put32(fat, (i+2)*4, next)
+	}
+
+	dir := make([]byte, sectorSize)
+	entry := func(i int, name string, typ byte, child uint32, start uint32, size int) {
+		e := dir[i*128:]
+		units := utf16.Encode([]rune(name))
+		for j, u := range units {
+			put16(e, j*2, int(u))
+		}
+		put16(e, 64, (len(units)+1)*2)
+		e[66] = typ
+		e[67] = 1
+		put32(e, 68, noStream)
+		put32(e, 72, noStream)
+		put32(e, 76, child)
+		put32(e, 116, start)
+		put32(e, 120, uint32(size))
+	}
+	entry(0, "Root Entry", 5, 1, endOfChain, 0)
+	entry(1, name, 2, noStream, 2, len(stream))
+	for i := 2; i < 4; i++ {
+		put32(dir[i*128:], 68, noStream)
+		put32(dir[i*128:], 72, noStream)
+		put32(dir[i*128:], 76, noStream)
+	}
+
+	return bytes.Join([][]byte{header, fat, dir, stream}, nil)
+}
+
+// newTestXlsWorkbook creates a workbook like newTestWorkbook, with a title row, a header, one row of each
+// cell record and a second sheet
+func newTestXlsWorkbook() []byte {
+	// "Monthly report" continues in the next chunk, in 16 bit characters
+	sst := [][]byte{
+		bytes.Join([][]byte{le32(4), le32(4), le16(14), {0}, []byte("Monthly ")}, nil),
+		bytes.Join([][]byte{{1}, []byte("r\x00e\x00p\x00o\x00r\x00t\x00"),
+			biffString("name", 2), biffString("a", 2), biffString("b", 2)}, nil),
+	}
+
+	report := testSheet{name: "Report", records: [][]byte{
+		biffRecord(recLabelSST, cell(0, 0, 0), le32(0)),
+
+		biffRecord(recLabelSST, cell(1, 0, 0), le32(1)),
+		biffRecord(recLabel, cell(1, 1, 0), biffString("amount", 2)),
+		biffRecord(recLabel, cell(1, 2, 0), biffString("rate", 2)),
+		biffRecord(recLabel, cell(1, 3, 0), biffString("day", 2)),
+		biffRecord(recLabel, cell(1, 4, 0), biffString("updated", 2)),
+		biffRecord(recLabel, cell(1, 5, 0), biffString("active", 2)),
+		biffRecord(recLabel, cell(1, 6, 0), biffString("code", 2)),
+		biffRecord(recLabel, cell(1, 7, 0), biffString("note", 2)),
+
+		biffRecord(recLabelSST, cell(2, 0, 0), le32(2)),
+		biffRecord(recNumber, cell(2, 1, 0), float(12.5)),
+		// 1.5 as 150 / 100, then 2026-10-15
+		biffRecord(recMulRk, le16(2), le16(2), le16(0), le32(150<<2|0x03), le16(1), le32(46310<<2|0x02), le16(3)),
+		biffRecord(recNumber, cell(2, 4, 2), float(46310+13.75/24)),
+		biffRecord(recBoolErr, cell(2, 5, 0), []byte{1, 0}),
+		biffRecord(recFormula, cell(2, 6, 0), []byte{0, 0, 0, 0, 0, 0, 0xFF, 0xFF}, make([]byte, 6)),
+		biffRecord(recString, biffString("né", 2)),
+		biffRecord(recLabel, cell(2, 7, 0), biffString("x", 2)),
+
+		biffRecord(recLabelSST, cell(3, 0, 0), le32(3)),
+		biffRecord(recRK, cell(3, 1, 0), le32(3<<2|0x02)),
+		biffRecord(recFormula, cell(3, 6, 0), float(7), make([]byte, 6)),
+		biffRecord(recFormula, cell(3, 7, 0), []byte{2, 0, 0x07, 0, 0, 0, 0xFF, 0xFF}, make([]byte, 6)),
+	}}
+
+	other := testSheet{name: "Other", records: [][]byte{
+		biffRecord(recLabel, cell(0, 0, 0), biffString("id", 2)),
+		biffRecord(recRK, cell(1, 0, 0), le32(1<<2|0x02)),
+	}}
+
+	return newTestXls(sst, report, other)
+}
+
+func TestXlsReader(t *testing.T) {
+	x, err := newXlsReader(bytes.NewReader(newTestXlsWorkbook()), "", 0, 1)
+	assert.Nil(t, err)
+
+	header, err := x.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, header, []string{"name", "amount", "rate", "day", "updated", "active", "code", "note"})
+
+	line, err := x.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, line, []string{"a", "12.5", "1.5", "2026-10-15", "2026-10-15 13:45:00", "1", "né", "x"})
+
+	// padded to the header
+	line, err = x.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, line, []string{"b", "3", "", "", "", "", "7", "#DIV/0!"})
+
+	_, err = x.Read()
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestXlsReaderTitle(t *testing.T) {
+	x, err := newXlsReader(bytes.NewReader(newTestXlsWorkbook()), "", 0, 0)
+	assert.Nil(t, err)
+
+	// the shared string split between the SST record and its CONTINUE record
+	title, err := x.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, title, []string{"Monthly report"})
+}
+
+func TestXlsReaderWideRow(t *testing.T) {
+	data := newTestXls(nil, testSheet{name: "Sheet1", records: [][]byte{
+		biffRecord(recLabel, cell(0, 0, 0), biffString("id", 2)),
+		biffRecord(recLabel, cell(0, 1, 0), biffString("name", 2)),
+		biffRecord(recRK, cell(1, 0, 0), le32(1<<2|0x02)),
+		biffRecord(recLabel, cell(1, 1, 0), biffString("a", 2)),
+		biffRecord(recRK, cell(2, 0, 0), le32(2<<2|0x02)),
+		biffRecord(recLabel, cell(2, 1, 0), biffString("b", 2)),
+		biffRecord(recLabel, cell(2, 3, 0), biffString("stray", 2)),
+	}})
+
+	x, err := newXlsReader(bytes.NewReader(data), "", 0, 0)
+	assert.Nil(t, err)
+
+	for _, expected := range [][]string{{"id", "name"}, {"1", "a"}} {
+		line, err := x.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, line, expected)
+	}
+
+	_, err = x.Read()
+	assert.Equal(t, err.Error(), "row 3 has 4 columns, header has 2")
+}
+
+func TestXlsReaderSheet(t *testing.T) {
+	data := newTestXlsWorkbook()
+
+	for _, x := range []func() (*xlsReader, error){
+		func() (*xlsReader, error) { return newXlsReader(bytes.NewReader(data), "Other", 0, 0) },
+		func() (*xlsReader, error) { return newXlsReader(bytes.NewReader(data), "", 2, 0) },
+	} {
+		r, err := x()
+		assert.Nil(t, err)
+
+		header, err := r.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, header, []string{"id"})
+	}
+
+	_, err := newXlsReader(bytes.NewReader(data), "", 3, 0)
+	assert.NotNil(t, err)
+	_, err = newXlsReader(bytes.NewReader(data), "Missing", 0, 0)
+	assert.NotNil(t, err)
+}
+
+func TestXlsReaderInvalid(t *testing.T) {
+	_, err := newXlsReader(bytes.NewReader([]byte("a;b\n1;2\n")), "", 0, 0)
+	assert.NotNil(t, err)
+
+	// encrypted
+	stream := bytes.Join([][]byte{
+		biffRecord(recBOF, le16(biff8Version), le16(0x0005), make([]byte, 12)),
+		biffRecord(recFilePass, make([]byte, 6)),
+		biffRecord(recEOF),
+	}, nil)
+	_, err = newXlsReader(bytes.NewReader(compoundFile("Workbook", stream)), "", 0, 0)
+	assert.Equal(t, err.Error(), "encrypted xls files are not supported")
+
+	// Excel 5
+	_, err = newXlsReader(bytes.NewReader(compoundFile("Book", stream)), "", 0, 0)
+	assert.Equal(t, err.Error(), "xls files older than Excel 97 are not supported")
+}
+
+func TestXlsInputFile(t *testing.T) {
+	assert.True(t, IsInputFile("Report.XLS"))
+	assert.Equal(t, BaseName("report.xls"), "report")
+	assert.Equal(t, inputFormat("report.xls", viper.New()), FormatXls)
+}
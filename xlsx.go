@@ -0,0 +1,275 @@
+package csv2table
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// kinds of date number formats of a cell
+const (
+	notDate = iota
+	dateOnly
+	timeOnly
+	dateTime
+)
+
+// xlsxReader reads the rows of an Excel worksheet. Numbers are read unformatted and dates, stored by Excel
+// as serial numbers, are converted to the mysql formats, e.g. 2006-01-02 15:04:05. Looking up the style
+// of a cell is slow, the number format of a column is the one of its first number.
+type xlsxReader struct {
+	f        *excelize.File
+	rows     *excelize.Rows
+	sheet    string
+	row      int // current row number, 1 based
+	width    int // number of columns of the header
+	date1904 bool
+
+	dateKinds map[int]int // date kind by style id
+	colKinds  map[int]int // date kind by column, 1 based
+}
+
+// newXlsxReader opens a workbook and selects a sheet by name, or by index (1 based) if sheet is empty.
+// The first sheet is read by default.
+func newXlsxReader(r io.Reader, sheet string, sheetIndex int, skipRows int) (*xlsxReader, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &xlsxReader{f: f, dateKinds: make(map[int]int), colKinds: make(map[int]int)}
+
+	if sheet == "" {
+		sheets := f.GetSheetList()
+		if sheetIndex < 1 {
+			sheetIndex = 1
+		}
+		if sheetIndex > len(sheets) {
+			f.Close()
+			return nil, fmt.Errorf("sheet %d not found, the workbook has %d sheets", sheetIndex, len(sheets))
+		}
+		sheet = sheets[sheetIndex-1]
+	}
+	x.sheet = sheet
+
+	props, err := f.GetWorkbookProps()
+	if err == nil && props.Date1904 != nil {
+		x.date1904 = *props.Date1904
+	}
+
+	x.rows, err = f.Rows(sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	for i := 0; i < skipRows; i++ {
+		if !x.rows.Next() {
+			break
+		}
+		x.row++
+	}
+
+	return x, nil
+}
+
+// Read returns the next row, padded to the number of columns of the header. Empty rows are skipped.
+// A row with values to the right of the last header column is an error, the empty cells there are ignored.
+func (x *xlsxReader) Read() ([]string, error) {
+	for x.rows.Next() {
+		x.row++
+
+		cols, err := x.rows.Columns(excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, err
+		}
+		cols, err = fitRow(cols, &x.width, x.row)
+		if err != nil {
+			return nil, err
+		}
+		if cols == nil {
+			continue
+		}
+
+		for i, value := range cols {
+			cols[i], err = x.cellValue(i+1, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return cols, nil
+	}
+
+	err := x.rows.Error()
+	if err == nil {
+		err = io.EOF
+	}
+
+	return nil, err
+}
+
+// fitRow fits the cells of a worksheet row to the header width, the number of cells of the first row if
+// width is 0. The trailing empty cells are dropped, the missing cells added, and an empty row returns nil.
+func fitRow(cols []string, width *int, row int) ([]string, error) {
+	n := len(cols)
+	for n > 0 && cols[n-1] == "" {
+		n--
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	if *width == 0 {
+		*width = n
+	}
+	if n > *width {
+		return nil, fmt.Errorf("row %d has %d columns, header has %d", row, n, *width)
+	}
+
+	cols = cols[:n]
+	for len(cols) < *width {
+		cols = append(cols, "")
+	}
+
+	return cols, nil
+}
+
+// Close closes the workbook
+func (x *xlsxReader) Close() error {
+	x.rows.Close()
+	return x.f.Close()
+}
+
+// cellValue converts the raw value of a numeric cell: dates to the mysql formats, numbers to plain decimals
+func (x *xlsxReader) cellValue(col int, value string) (string, error) {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		// not a number
+		return value, nil
+	}
+
+	cell, err := excelize.CoordinatesToCellName(col, x.row)
+	if err != nil {
+		return "", err
+	}
+
+	kind, exists := x.colKinds[col]
+	if !exists {
+		kind, err = x.dateKind(cell)
+		if err != nil {
+			return "", err
+		}
+		x.colKinds[col] = kind
+	}
+
+	if kind == notDate {
+		// e.g. 1.5E-3
+		if strings.ContainsAny(value, "eE") {
+			return strconv.FormatFloat(number, 'f', -1, 64), nil
+		}
+		return value, nil
+	}
+
+	date, err := excelDate(number, kind, x.date1904)
+	if err != nil {
+		return "", fmt.Errorf("invalid date in cell %s, %v", cell, err)
+	}
+
+	return date, nil
+}
+
+// excelDate converts a date, stored by Excel as a serial number, to the mysql format of its date kind
+func excelDate(number float64, kind int, date1904 bool) (string, error) {
+	t, err := excelize.ExcelDateToTime(number, date1904)
+	if err != nil {
+		return "", err
+	}
+
+	switch kind {
+	case dateOnly:
+		return t.Format("2006-01-02"), nil
+	case timeOnly:
+		return t.Format("15:04:05"), nil
+	}
+
+	return t.Format("2006-01-02 15:04:05"), nil
+}
+
+// dateKind returns the date kind of the number format of a cell
+func (x *xlsxReader) dateKind(cell string) (int, error) {
+	styleID, err := x.f.GetCellStyle(x.sheet, cell)
+	if err != nil {
+		return notDate, err
+	}
+
+	kind, exists := x.dateKinds[styleID]
+	if exists {
+		return kind, nil
+	}
+
+	style, err := x.f.GetStyle(styleID)
+	if err != nil {
+		return notDate, err
+	}
+
+	if style.CustomNumFmt != nil {
+		kind = numFmtDateKind(*style.CustomNumFmt)
+	} else {
+		kind = builtInDateKind(style.NumFmt)
+	}
+	x.dateKinds[styleID] = kind
+
+	return kind, nil
+}
+
+// builtInDateKind returns the date kind of a built-in number format
+func builtInDateKind(numFmt int) int {
+	switch {
+	case numFmt >= 14 && numFmt <= 17:
+		return dateOnly
+	case numFmt >= 18 && numFmt <= 21, numFmt >= 45 && numFmt <= 47:
+		return timeOnly
+	case numFmt == 22:
+		return dateTime
+	}
+
+	return notDate
+}
+
+// numFmtDateKind returns the date kind of a custom number format, e.g. dd.mm.yyyy hh:mm.
+// Literal text and sections in brackets, like colors or locales, are ignored.
+func numFmtDateKind(code string) int {
+	var b strings.Builder
+	quoted, bracket := false, false
+	for _, c := range strings.ToLower(code) {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			bracket = true
+		case c == ']':
+			bracket = false
+		case !bracket:
+			b.WriteRune(c)
+		}
+	}
+	code = b.String()
+
+	hasDate := strings.ContainsAny(code, "yd")
+	hasTime := strings.ContainsAny(code, "hs")
+	switch {
+	case hasDate && hasTime:
+		return dateTime
+	case hasDate:
+		return dateOnly
+	case hasTime:
+		return timeOnly
+	}
+
+	return notDate
+}
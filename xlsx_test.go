@@ -0,0 +1,127 @@
+package csv2table
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+// newTestWorkbook creates a workbook with a title row, a header and one row of each cell kind,
+// and a second sheet
+func newTestWorkbook(t *testing.T) *bytes.Buffer {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+
+	dateStyle, err := f.NewStyle(&excelize.Style{NumFmt: 14})
+	assert.Nil(t, err)
+	customFmt := "dd.mm.yyyy hh:mm"
+	dateTimeStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &customFmt})
+	assert.Nil(t, err)
+
+	f.SetSheetRow(sheet, "A1", &[]interface{}{"Monthly report"})
+	f.SetSheetRow(sheet, "A2", &[]interface{}{"name", "amount", "small", "day", "updated", "note"})
+	f.SetSheetRow(sheet, "A3", &[]interface{}{"a", 12.5, 0.0000015, 0, 0, "x"})
+	f.SetSheetRow(sheet, "A4", &[]interface{}{"b", 3})
+
+	f.SetCellValue(sheet, "D3", time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC))
+	f.SetCellStyle(sheet, "D3", "D3", dateStyle)
+	f.SetCellValue(sheet, "E3", time.Date(2026, 10, 15, 13, 45, 0, 0, time.UTC))
+	f.SetCellStyle(sheet, "E3", "E3", dateTimeStyle)
+
+	f.NewSheet("Other")
+	f.SetSheetRow("Other", "A1", &[]interface{}{"id"})
+	f.SetSheetRow("Other", "A2", &[]interface{}{1})
+
+	buf, err := f.WriteToBuffer()
+	assert.Nil(t, err)
+
+	return buf
+}
+
+func TestXlsxReader(t *testing.T) {
+	x, err := newXlsxReader(newTestWorkbook(t), "", 0, 1)
+	assert.Nil(t, err)
+	defer x.Close()
+
+	header, err := x.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, header, []string{"name", "amount", "small", "day", "updated", "note"})
+
+	line, err := x.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, line, []string{"a", "12.5", "0.0000015", "2026-10-15", "2026-10-15 13:45:00", "x"})
+
+	// padded to the header
+	line, err = x.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, line, []string{"b", "3", "", "", "", ""})
+
+	// the style is looked up once per column of numbers
+	assert.Equal(t, x.colKinds, map[int]int{2: notDate, 3: notDate, 4: dateOnly, 5: dateTime})
+
+	_, err = x.Read()
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestXlsxReaderWideRow(t *testing.T) {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	f.SetSheetRow(sheet, "A1", &[]interface{}{"id", "name"})
+	f.SetSheetRow(sheet, "A2", &[]interface{}{1, "a"})
+	f.SetSheetRow(sheet, "A3", &[]interface{}{2, "b", "", ""})
+	f.SetSheetRow(sheet, "A4", &[]interface{}{3, "c", "", "stray"})
+	buf, err := f.WriteToBuffer()
+	assert.Nil(t, err)
+
+	x, err := newXlsxReader(buf, "", 0, 0)
+	assert.Nil(t, err)
+	defer x.Close()
+
+	for _, expected := range [][]string{{"id", "name"}, {"1", "a"}, {"2", "b"}} {
+		line, err := x.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, line, expected)
+	}
+
+	_, err = x.Read()
+	assert.Equal(t, err.Error(), "row 4 has 4 columns, header has 2")
+}
+
+func TestXlsxReaderSheet(t *testing.T) {
+	buf := newTestWorkbook(t)
+
+	for _, x := range []func() (*xlsxReader, error){
+		func() (*xlsxReader, error) { return newXlsxReader(bytes.NewReader(buf.Bytes()), "Other", 0, 0) },
+		func() (*xlsxReader, error) { return newXlsxReader(bytes.NewReader(buf.Bytes()), "", 2, 0) },
+	} {
+		r, err := x()
+		assert.Nil(t, err)
+
+		header, err := r.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, header, []string{"id"})
+		r.Close()
+	}
+
+	_, err := newXlsxReader(bytes.NewReader(buf.Bytes()), "", 3, 0)
+	assert.NotNil(t, err)
+}
+
+func TestNumFmtDateKind(t *testing.T) {
+	assert.Equal(t, numFmtDateKind("dd.mm.yyyy"), dateOnly)
+	assert.Equal(t, numFmtDateKind("hh:mm:ss"), timeOnly)
+	assert.Equal(t, numFmtDateKind("yyyy-mm-dd hh:mm"), dateTime)
+	assert.Equal(t, numFmtDateKind("#,##0.00 \"days\""), notDate)
+	assert.Equal(t, numFmtDateKind("[Red][$USD] #,##0.00"), notDate)
+}
+
+func TestXlsxInputFile(t *testing.T) {
+	assert.True(t, IsInputFile("Report.XLSX"))
+	assert.Equal(t, BaseName("report.xlsx"), "report")
+	assert.Equal(t, inputFormat("report.xlsx", viper.New()), FormatXlsx)
+}